@@ -30,10 +30,19 @@ var (
 
 // help message
 const helpMessage = `Usage:
-1. Send me '/mensa lunch' or '/mensa dinner' to get today's menus.
-2. Send me a sticker to tag.
-3. Use my inline mode to search for stickers given a tag.
-4. Send me /help to show this message again.`
+1. Send me '/mensa' to get the menus of the next meal, or '/mensa lunch' or '/mensa dinner' to get today's menus.
+   Add 'short' to get a single summary message, or set it as default with '/mensa default short'.
+   Add 'lowcarbon' to only get dishes with a low climate footprint.
+2. Share a location with me to get the menus of the nearest open mensas.
+3. Send me /subscribe to be notified when today's menus change, /unsubscribe to stop.
+4. Send me a sticker to tag, followed by its tags separated by commas or spaces and /done.
+   Use /untag <tag> after sending a sticker to remove one of its tags.
+   Press 'Tag whole pack' to add the tags to every sticker of its pack.
+   New stickers are tagged with their emoji and pack title, turn this off with /autotag off.
+   Use /rename <old> <new> to rename a tag and /merge <from> <into> to merge two tags.
+5. Use my inline mode to search for stickers given a tag.
+   An empty query shows your favorite and recently used stickers, send /fav after a sticker to star or unstar it.
+6. Send me /help to show this message again.`
 
 // init function runs automatically before the main function
 // not work in render
@@ -49,6 +58,17 @@ func main() {
 	initLogger()
 	defer logFile.Close()
 
+	// run the backup tool instead of the bot, e.g. `./app export tags.json`
+	if len(os.Args) > 1 {
+		if err := sticker.InitStore(Logger); err != nil {
+			Logger.Fatal(err)
+		}
+		if err := sticker.RunBackupCommand(os.Args[1:], Logger); err != nil {
+			Logger.Fatal(err)
+		}
+		return
+	}
+
 	// create the bot
 	token := os.Getenv("TELEGRAM_BOT_TOKEN")
 	bot, err = tgbotapi.NewBotAPI(token)
@@ -59,6 +79,23 @@ func main() {
 
 	tgbotapi.SetLogger(Logger)
 
+	// open the tag store selected by TAG_STORE
+	if err := sticker.InitStore(Logger); err != nil {
+		Logger.Fatal(err)
+	}
+
+	// migrate stickers stored by an older version
+	if err := sticker.MigrateStickerIndex(Logger); err != nil {
+		Logger.Errorf("Error migrating the sticker index: %v", err)
+	} else if err := sticker.MigrateTags(bot, Logger); err != nil {
+		Logger.Errorf("Error migrating tags: %v", err)
+	} else if err := sticker.MigrateTagKeys(Logger); err != nil {
+		Logger.Errorf("Error migrating tag keys: %v", err)
+	}
+
+	// notify subscribers about menu changes
+	mensa.StartMenuWatcher(bot, Logger)
+
 	// switch between long polling and webhook
 	useWebhook = os.Getenv("USE_WEBHOOK") == "true"
 	if useWebhook {
@@ -98,6 +135,10 @@ func StartHTTPServer() {
 			"message": "Bot server is healthy!",
 		})
 	})
+	// Abstract API usage
+	router.GET("/usage", func(c *gin.Context) {
+		c.JSON(200, mensa.AbstractApiUsage(Logger))
+	})
 	// for render keep alive
 	router.HEAD("/keep-alive", func(c *gin.Context) {
 		c.Status(200)
@@ -132,6 +173,10 @@ func handleUpdate(update tgbotapi.Update) {
 		userID = update.InlineQuery.From.ID
 	case update.Message != nil:
 		userID = update.Message.From.ID
+	case update.CallbackQuery != nil:
+		userID = update.CallbackQuery.From.ID
+	case update.ChosenInlineResult != nil:
+		userID = update.ChosenInlineResult.From.ID
 	default:
 		return // Ignore other types of updates
 	}
@@ -151,12 +196,38 @@ func handleUpdate(update tgbotapi.Update) {
 	case update.InlineQuery != nil:
 		sticker.SearchStickers(bot, update.InlineQuery, Logger)
 		break
+	// Handle stickers chosen from inline results, requires inline feedback in @BotFather
+	case update.ChosenInlineResult != nil:
+		sticker.RecordChosenSticker(update.ChosenInlineResult, Logger)
+		break
+	// Handle inline keyboard buttons
+	case update.CallbackQuery != nil:
+		sticker.HandleCallbackQuery(bot, update.CallbackQuery, Logger)
+		break
 	// Handle messages
 	case update.Message != nil:
-		if strings.EqualFold(update.Message.Text, "/mensa lunch") {
-			mensa.SendMensaMenues(bot, update.Message, "Lunch", Logger)
-		} else if strings.EqualFold(update.Message.Text, "/mensa dinner") {
-			mensa.SendMensaMenues(bot, update.Message, "Dinner", Logger)
+		if update.Message.Location != nil {
+			mensa.SendNearestMensaMenus(bot, update.Message, Logger)
+		} else if isCommand(update.Message.Text, "/mensa") {
+			mensa.HandleMensaCommand(bot, update.Message, Logger)
+		} else if isCommand(update.Message.Text, "/subscribe") {
+			mensa.SetMenuSubscription(bot, update.Message, true, Logger)
+		} else if isCommand(update.Message.Text, "/unsubscribe") {
+			mensa.SetMenuSubscription(bot, update.Message, false, Logger)
+		} else if isCommand(update.Message.Text, "/usage") {
+			mensa.SendApiUsage(bot, update.Message, Logger)
+		} else if isCommand(update.Message.Text, "/export") {
+			sticker.ExportTags(bot, update.Message, Logger)
+		} else if isCommand(update.Message.Text, "/import") || isCommand(update.Message.Caption, "/import") {
+			sticker.ImportTags(bot, update.Message, Logger)
+		} else if isCommand(update.Message.Text, "/autotag") {
+			sticker.SetAutoTags(bot, update.Message, Logger)
+		} else if isCommand(update.Message.Text, "/fav") {
+			sticker.ToggleFavorite(bot, update.Message, Logger)
+		} else if isCommand(update.Message.Text, "/rename") {
+			sticker.RenameTag(bot, update.Message, Logger)
+		} else if isCommand(update.Message.Text, "/merge") {
+			sticker.MergeTags(bot, update.Message, Logger)
 		} else if strings.HasPrefix(update.Message.Text, "/delete") {
 			sticker.DeleteTag(bot, update.Message, Logger)
 		} else if strings.HasPrefix(update.Message.Text, "/help") {
@@ -169,12 +240,18 @@ func handleUpdate(update tgbotapi.Update) {
 	}
 }
 
+// Whether the text is the given command, with or without arguments
+func isCommand(text string, command string) bool {
+	fields := strings.Fields(text)
+	return len(fields) > 0 && strings.EqualFold(fields[0], command)
+}
+
 // Use webhook to receive updates
 // This is used for production
 func startWebhook() {
 	// Configure the webhook
 	webhook, err := tgbotapi.NewWebhook(os.Getenv("WEBHOOK_URL") + bot.Token)
-	webhook.AllowedUpdates = []string{"message", "inline_query"}
+	webhook.AllowedUpdates = []string{"message", "inline_query", "chosen_inline_result", "callback_query"}
 	if err != nil {
 		Logger.Fatal(err)
 	}
@@ -210,7 +287,7 @@ func startPolling() {
 	// The timer is reset every time the bot receives an update
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
-	u.AllowedUpdates = []string{"message", "inline_query"}
+	u.AllowedUpdates = []string{"message", "inline_query", "chosen_inline_result", "callback_query"}
 
 	updates := bot.GetUpdatesChan(u)
 
@@ -0,0 +1,226 @@
+package mensa
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	utils "pbaobot/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// kinds of menu changes
+const (
+	DishAdded    = "added"
+	DishRemoved  = "removed"
+	DishReplaced = "replaced"
+)
+
+// A change between two scrapes of the same mensa and date
+type MenuChange struct {
+	Location string
+	Date     string
+	Type     string // lunch or dinner
+	Category string
+	Kind     string // added, removed or replaced
+	Old      string // title before the change
+	New      string // title after the change
+}
+
+var (
+	// maps from "<date>/<location>" to the last seen menus
+	menuSnapshots = utils.NewJSONFile("MENU_SNAPSHOTS_PATH", "menu_snapshots.json", make(map[string][]MenuItem))
+	// chats notified about menu changes
+	menuSubscribers = utils.NewJSONFile[[]int64]("MENU_SUBSCRIBERS_PATH", "menu_subscribers.json", nil)
+	// the bot used to notify subscribers, set by `StartMenuWatcher`
+	notifyBot *tgbotapi.BotAPI
+)
+
+// Return the keys identifying the dishes across scrapes, in the order of the menus
+// Dishes sharing a category are told apart by their position within the category
+func dishKeys(menus []MenuItem) []string {
+	keys := make([]string, len(menus))
+	counts := make(map[string]int)
+	for i, menu := range menus {
+		key := menu.Type + "/" + menu.Title
+		if menu.Category != "" {
+			key = menu.Type + "/" + menu.Category
+		}
+		keys[i] = fmt.Sprintf("%s/%d", key, counts[key])
+		counts[key]++
+	}
+	return keys
+}
+
+// Return the changes from the old to the new menus of a mensa and date
+func diffMenus(location string, date string, oldMenus []MenuItem, newMenus []MenuItem) []MenuChange {
+	oldKeys := dishKeys(oldMenus)
+	oldDishes := make(map[string]MenuItem)
+	for i, menu := range oldMenus {
+		oldDishes[oldKeys[i]] = menu
+	}
+
+	newKeys := dishKeys(newMenus)
+	var changes []MenuChange
+	seen := make(map[string]bool)
+	for i, menu := range newMenus {
+		key := newKeys[i]
+		seen[key] = true
+		change := MenuChange{Location: location, Date: date, Type: menu.Type, Category: menu.Category, New: menu.Title}
+		oldMenu, ok := oldDishes[key]
+		switch {
+		case !ok:
+			change.Kind = DishAdded
+		case oldMenu.Title != menu.Title:
+			change.Kind = DishReplaced
+			change.Old = oldMenu.Title
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	for i, menu := range oldMenus {
+		if !seen[oldKeys[i]] {
+			changes = append(changes, MenuChange{Location: location, Date: date, Type: menu.Type,
+				Category: menu.Category, Kind: DishRemoved, Old: menu.Title})
+		}
+	}
+	return changes
+}
+
+// Store the scraped menus of a mensa and date and return the changes since the last scrape
+// The first scrape of a mensa and date has no changes
+func recordMenuSnapshot(location string, date string, menus []MenuItem, logger *utils.BotLogger) []MenuChange {
+	var oldMenus []MenuItem
+	var ok bool
+	err := menuSnapshots.Update(logger, func(snapshots *map[string][]MenuItem) error {
+		key := date + "/" + location
+		oldMenus, ok = (*snapshots)[key]
+		(*snapshots)[key] = menus
+
+		// forget the snapshots of past days
+		today := utils.Today()
+		for snapshotKey := range *snapshots {
+			if strings.SplitN(snapshotKey, "/", 2)[0] < today {
+				delete(*snapshots, snapshotKey)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("Error saving menu snapshots: %v", err)
+	}
+
+	if !ok {
+		return nil
+	}
+	return diffMenus(location, date, oldMenus, menus)
+}
+
+// Return the text describing the changes
+func menuChangesText(changes []MenuChange) string {
+	var text strings.Builder
+	change := changes[0]
+	text.WriteString(fmt.Sprintf("Menu update for %s on %s:\n", change.Location, change.Date))
+	for _, change := range changes {
+		switch change.Kind {
+		case DishAdded:
+			text.WriteString(fmt.Sprintf("+ %s %s: %s\n", change.Type, change.Category, change.New))
+		case DishRemoved:
+			text.WriteString(fmt.Sprintf("- %s %s: %s\n", change.Type, change.Category, change.Old))
+		case DishReplaced:
+			text.WriteString(fmt.Sprintf("~ %s %s: %s -> %s\n", change.Type, change.Category, change.Old, change.New))
+		}
+	}
+	return text.String()
+}
+
+// Notify the subscribers about the changes of a mensa and date
+func notifyMenuChanges(changes []MenuChange, logger *utils.BotLogger) {
+	if len(changes) == 0 {
+		return
+	}
+	logger.Infof("Menus of %s on %s changed: %d changes", changes[0].Location, changes[0].Date, len(changes))
+	if notifyBot == nil {
+		return
+	}
+
+	var subscribers []int64
+	menuSubscribers.View(logger, func(chatIDs []int64) {
+		subscribers = append(subscribers, chatIDs...)
+	})
+
+	text := menuChangesText(changes)
+	for _, chatID := range subscribers {
+		msg := tgbotapi.NewMessage(chatID, text)
+		if _, err := notifyBot.Send(msg); err != nil {
+			logger.Errorf("Error notifying chat %d: %v", chatID, err)
+		}
+	}
+}
+
+// Subscribe or unsubscribe the chat from menu change notifications
+func SetMenuSubscription(bot *tgbotapi.BotAPI, message *tgbotapi.Message, subscribe bool, logger *utils.BotLogger) {
+	err := menuSubscribers.Update(logger, func(chatIDs *[]int64) error {
+		var subscribers []int64
+		for _, chatID := range *chatIDs {
+			if chatID != message.Chat.ID {
+				subscribers = append(subscribers, chatID)
+			}
+		}
+		if subscribe {
+			subscribers = append(subscribers, message.Chat.ID)
+		}
+		*chatIDs = subscribers
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("Error saving menu subscribers: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to update the subscription. Please try again.")
+		bot.Send(msg)
+		return
+	}
+
+	text := "You will be notified when today's menus change. Use /unsubscribe to stop."
+	if !subscribe {
+		text = "You will no longer be notified about menu changes."
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	bot.Send(msg)
+}
+
+// Notify subscribers about menu changes, and re-scrape today's menus
+// every `MENU_WATCH_INTERVAL` minutes while the mensas are open
+// Re-scraping costs Abstract API calls and is disabled if the interval is not set
+func StartMenuWatcher(bot *tgbotapi.BotAPI, logger *utils.BotLogger) {
+	notifyBot = bot
+
+	interval, err := strconv.Atoi(os.Getenv("MENU_WATCH_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			var hasSubscribers bool
+			menuSubscribers.View(logger, func(chatIDs []int64) {
+				hasSubscribers = len(chatIDs) > 0
+			})
+
+			now := utils.Now()
+			mealType, date := nextMeal(now)
+			if !hasSubscribers || date.Format("2006-01-02") != now.Format("2006-01-02") {
+				continue
+			}
+			logger.Infof("Checking today's menus for %s changes", strings.ToLower(mealType))
+			if _, err := AllEthMenus(utils.Today(), logger); err != nil {
+				logger.Errorf("Error checking menus: %v", err)
+			}
+		}
+	}()
+}
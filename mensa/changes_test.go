@@ -0,0 +1,27 @@
+package mensa
+
+import "testing"
+
+func TestDiffMenus(t *testing.T) {
+	menus := []MenuItem{
+		{Type: "Lunch", Category: "Local", Title: "Rösti"},
+		{Type: "Lunch", Category: "Local", Title: "Älplermagronen"},
+		{Type: "Lunch", Category: "Vegan", Title: "Curry"},
+	}
+
+	if changes := diffMenus("Polymensa", "2026-10-19", menus, menus); len(changes) != 0 {
+		t.Errorf("diffMenus() of the same menus = %v, want no changes", changes)
+	}
+
+	replaced := append([]MenuItem(nil), menus...)
+	replaced[1].Title = "Zürcher Geschnetzeltes"
+	changes := diffMenus("Polymensa", "2026-10-19", menus, replaced)
+	if len(changes) != 1 || changes[0].Kind != DishReplaced || changes[0].Old != "Älplermagronen" {
+		t.Errorf("diffMenus() = %v, want Älplermagronen replaced", changes)
+	}
+
+	changes = diffMenus("Polymensa", "2026-10-19", menus, menus[:2])
+	if len(changes) != 1 || changes[0].Kind != DishRemoved || changes[0].Old != "Curry" {
+		t.Errorf("diffMenus() = %v, want Curry removed", changes)
+	}
+}
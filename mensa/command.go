@@ -0,0 +1,107 @@
+package mensa
+
+import (
+	"fmt"
+	"strings"
+
+	utils "pbaobot/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// usage of the /mensa command
+const mensaUsage = `Usage:
+/mensa - menus of the next meal
+/mensa lunch - today's lunch menus
+/mensa dinner - today's dinner menus
+Add 'short' for a single summary message or 'photo' for one message per menu, e.g. '/mensa lunch short'.
+Add 'lowcarbon' to only get dishes with a low climate footprint.
+/mensa default short|photo - set how menus are sent by default
+/mensa export [today|week] [csv|json] - get the menus as a document`
+
+// Options of a menu request
+type MenuOptions struct {
+	MealType  string // lunch or dinner, empty for all meals
+	Date      string // in the format "YYYY-MM-DD"
+	Compact   bool   // send a single summary message
+	LowCarbon bool   // only send dishes with a low climate footprint
+}
+
+// Handle the /mensa command and its arguments
+func HandleMensaCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	args := strings.Fields(strings.ToLower(message.Text))[1:]
+
+	if len(args) > 0 && args[0] == "default" {
+		setDefaultMode(bot, message, args[1:], logger)
+		return
+	}
+	if len(args) > 0 && args[0] == "export" {
+		sendMenuExport(bot, message, args[1:], logger)
+		return
+	}
+
+	options := MenuOptions{
+		Date:    utils.Today(),
+		Compact: preferencesOf(message.From.ID, logger).Compact,
+	}
+	for _, arg := range args {
+		switch arg {
+		case "lunch":
+			options.MealType = "Lunch"
+		case "dinner":
+			options.MealType = "Dinner"
+		case "short":
+			options.Compact = true
+		case "photo":
+			options.Compact = false
+		case "lowcarbon":
+			options.LowCarbon = true
+		default:
+			msg := tgbotapi.NewMessage(message.Chat.ID, mensaUsage)
+			bot.Send(msg)
+			return
+		}
+	}
+
+	if options.MealType == "" {
+		sendNextMealMenus(bot, message, options, logger)
+		return
+	}
+	SendMensaMenues(bot, message, options, logger)
+}
+
+// Set whether menus are sent compact by default
+func setDefaultMode(bot *tgbotapi.BotAPI, message *tgbotapi.Message, args []string, logger *utils.BotLogger) {
+	if len(args) != 1 || (args[0] != "short" && args[0] != "photo") {
+		msg := tgbotapi.NewMessage(message.Chat.ID, mensaUsage)
+		bot.Send(msg)
+		return
+	}
+
+	preferences := preferencesOf(message.From.ID, logger)
+	preferences.Compact = args[0] == "short"
+	if err := setPreferences(message.From.ID, preferences, logger); err != nil {
+		logger.Errorf("Error saving mensa preferences: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to save your preference. Please try again.")
+		bot.Send(msg)
+		return
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Menus will be sent in %s mode by default.", args[0]))
+	bot.Send(msg)
+}
+
+// Send the menus of the next meal inferred from the current time
+func sendNextMealMenus(bot *tgbotapi.BotAPI, message *tgbotapi.Message, options MenuOptions, logger *utils.BotLogger) {
+	now := utils.Now()
+	mealType, date := nextMeal(now)
+	options.MealType = mealType
+	options.Date = date.Format("2006-01-02")
+
+	if options.Date != now.Format("2006-01-02") {
+		msg := tgbotapi.NewMessage(message.Chat.ID,
+			fmt.Sprintf("The mensas are closed for today, here are the %s menus of %s.",
+				strings.ToLower(mealType), date.Format("Monday, 2 January")))
+		bot.Send(msg)
+	}
+	SendMensaMenues(bot, message, options, logger)
+}
@@ -1,7 +1,11 @@
 package mensa
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	utils "pbaobot/utils"
@@ -18,22 +22,67 @@ type MenuItem struct {
 	ImageURL    string
 	Price       string
 	Type        string // lunch or dinner
+	Date        string // in the format "YYYY-MM-DD"
+	CO2Grams    int    // climate footprint in grams of CO2 equivalent, 0 if unknown
+	Climate     string // climate label, e.g. climate-friendly
+	Origin      string // origin of the meat
 }
 
-// Send all mensa menus given the meal type, one menu per message with image
-func SendMensaMenues(bot *tgbotapi.BotAPI, message *tgbotapi.Message, mealType string, logger *utils.BotLogger) {
-	menus, err := AllEthMenus()
+// dishes up to this climate footprint in grams of CO2 equivalent are low carbon if `MENSA_LOW_CARBON_GRAMS` is not set
+// This is not an official label but our own rough cut-off of 1 kg per dish, the mensas only publish the footprint
+const defaultLowCarbonThreshold = 1000
+
+// climate labels marking a dish as climate friendly, in lower case and without separators
+var positiveClimateLabels = []string{"climatefriendly", "klimafreundlich"}
+
+// Return the footprint in grams of CO2 equivalent up to which a dish is low carbon
+func lowCarbonThreshold() int {
+	threshold, err := strconv.Atoi(os.Getenv("MENSA_LOW_CARBON_GRAMS"))
+	if err != nil || threshold <= 0 {
+		return defaultLowCarbonThreshold
+	}
+	return threshold
+}
+
+// Whether the climate label marks the dish as climate friendly, other labels may rate it badly
+func isPositiveClimateLabel(label string) bool {
+	label = strings.ToLower(label)
+	if strings.Contains(label, "not ") || strings.Contains(label, "nicht ") {
+		return false
+	}
+	label = strings.NewReplacer(" ", "", "-", "", "_", "").Replace(label)
+	for _, positive := range positiveClimateLabels {
+		if strings.Contains(label, positive) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the dish is labeled climate friendly or has a low footprint
+func (menu MenuItem) IsLowCarbon() bool {
+	return isPositiveClimateLabel(menu.Climate) || (menu.CO2Grams > 0 && menu.CO2Grams <= lowCarbonThreshold())
+}
+
+// Send all mensa menus given the options, either one menu per message with image
+// or a single summary message
+func SendMensaMenues(bot *tgbotapi.BotAPI, message *tgbotapi.Message, options MenuOptions, logger *utils.BotLogger) {
+	menus, err := AllEthMenus(options.Date, logger)
 	if err != nil {
 		logger.Errorf("Error fetching menus: %v", err)
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, I couldn't fetch the menus. Please try again later.")
+		text := "Sorry, I couldn't fetch the menus. Please try again later."
+		if errors.Is(err, errBudgetExceeded) {
+			text = "Sorry, the scraping budget of this month is used up."
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
 		bot.Send(msg)
 		return
 	}
 
 	var filteredMenus []MenuItem
-	if mealType != "" {
+	if options.MealType != "" {
 		for _, menu := range menus {
-			if menu.Type == mealType {
+			if menu.Type == options.MealType {
 				filteredMenus = append(filteredMenus, menu)
 			}
 		}
@@ -42,34 +91,98 @@ func SendMensaMenues(bot *tgbotapi.BotAPI, message *tgbotapi.Message, mealType s
 	}
 	menus = filteredMenus
 
+	if options.LowCarbon {
+		filteredMenus = nil
+		for _, menu := range menus {
+			if menu.IsLowCarbon() {
+				filteredMenus = append(filteredMenus, menu)
+			}
+		}
+		menus = filteredMenus
+	}
+
+	if len(menus) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, I couldn't find any menus.")
+		bot.Send(msg)
+		return
+	}
+
+	if options.Compact {
+		sendMenuSummary(bot, message.Chat.ID, menus, logger)
+		return
+	}
 	for _, menu := range menus {
-		var text strings.Builder
-		text.WriteString(fmt.Sprintf("*%s - %s*\n", menu.Location, menu.Type))
-		text.WriteString(fmt.Sprintf("Category: %s\n", menu.Category))
-		text.WriteString(fmt.Sprintf("Title: %s\n", menu.Title))
-		text.WriteString(fmt.Sprintf("Description: %s\n", menu.Description))
-		text.WriteString(fmt.Sprintf("Price: %s\n", menu.Price))
-
-		msg := tgbotapi.NewMessage(message.Chat.ID, text.String())
-		msg.ParseMode = "Markdown"
-
-		if menu.ImageURL != "" {
-			photo := tgbotapi.NewPhoto(message.Chat.ID, tgbotapi.FileURL(menu.ImageURL))
-			photo.Caption = text.String()
-			photo.ParseMode = "Markdown"
-			_, err := bot.Send(photo)
-			if err != nil {
-				// Fall back to sending text message if photo fails
-				_, err = bot.Send(msg)
-				if err != nil {
-					logger.Errorf("Error sending message: %v", err)
-				}
+		sendMenuItem(bot, message.Chat.ID, menu, logger)
+	}
+}
+
+// Send all menus in a single message grouped by location, one line per dish
+func sendMenuSummary(bot *tgbotapi.BotAPI, chatID int64, menus []MenuItem, logger *utils.BotLogger) {
+	var locations []string
+	dishes := make(map[string][]MenuItem)
+	for _, menu := range menus {
+		if _, ok := dishes[menu.Location]; !ok {
+			locations = append(locations, menu.Location)
+		}
+		dishes[menu.Location] = append(dishes[menu.Location], menu)
+	}
+	sort.Strings(locations)
+
+	var text strings.Builder
+	for i, location := range locations {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+		text.WriteString(fmt.Sprintf("%s - %s\n", location, dishes[location][0].Type))
+		for _, menu := range dishes[location] {
+			text.WriteString(fmt.Sprintf("• %s", menu.Title))
+			if menu.Price != "" {
+				text.WriteString(fmt.Sprintf(" - %s", menu.Price))
 			}
-		} else {
-			_, err := bot.Send(msg)
+			text.WriteString("\n")
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	if _, err := bot.Send(msg); err != nil {
+		logger.Errorf("Error sending message: %v", err)
+	}
+}
+
+// Send a single menu item, with its image if available
+func sendMenuItem(bot *tgbotapi.BotAPI, chatID int64, menu MenuItem, logger *utils.BotLogger) {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("*%s - %s*\n", menu.Location, menu.Type))
+	text.WriteString(fmt.Sprintf("Category: %s\n", menu.Category))
+	text.WriteString(fmt.Sprintf("Title: %s\n", menu.Title))
+	text.WriteString(fmt.Sprintf("Description: %s\n", menu.Description))
+	text.WriteString(fmt.Sprintf("Price: %s\n", menu.Price))
+	if menu.Climate != "" {
+		text.WriteString(fmt.Sprintf("Climate: %s\n", menu.Climate))
+	}
+	if menu.CO2Grams > 0 {
+		text.WriteString(fmt.Sprintf("CO2: %d g\n", menu.CO2Grams))
+	}
+	if menu.Origin != "" {
+		text.WriteString(fmt.Sprintf("Origin: %s\n", menu.Origin))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.ParseMode = "Markdown"
+
+	if menu.ImageURL != "" {
+		err := sendMenuPhoto(bot, chatID, menu.ImageURL, text.String(), logger)
+		if err != nil {
+			// Fall back to sending text message if photo fails
+			_, err = bot.Send(msg)
 			if err != nil {
 				logger.Errorf("Error sending message: %v", err)
 			}
 		}
+	} else {
+		_, err := bot.Send(msg)
+		if err != nil {
+			logger.Errorf("Error sending message: %v", err)
+		}
 	}
 }
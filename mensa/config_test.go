@@ -0,0 +1,24 @@
+package mensa
+
+import "testing"
+
+func TestIsLowCarbon(t *testing.T) {
+	tests := []struct {
+		menu MenuItem
+		want bool
+	}{
+		{MenuItem{Climate: "Climate-friendly"}, true},
+		{MenuItem{Climate: "Klimafreundlich"}, true},
+		{MenuItem{Climate: "Climate impact: high"}, false},
+		{MenuItem{Climate: "Klimaunfreundlich"}, false},
+		{MenuItem{Climate: "Nicht klimafreundlich"}, false},
+		{MenuItem{CO2Grams: 800}, true},
+		{MenuItem{CO2Grams: 2500}, false},
+		{MenuItem{}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.menu.IsLowCarbon(); got != tt.want {
+			t.Errorf("IsLowCarbon() of %+v = %v, want %v", tt.menu, got, tt.want)
+		}
+	}
+}
@@ -2,13 +2,14 @@ package mensa
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
-	"time"
+
+	utils "pbaobot/utils"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -16,10 +17,14 @@ import (
 // Maps from ETH mensa location to its ID used in
 // https://ethz.ch/en/campus/erleben/gastronomie-und-einkaufen/gastronomie/menueplaene/offerDay.html?id=x
 var EthMensaId = map[string]int{
+	// Zentrum
 	"Clausiusbar":   3,
 	"PolyMensa":     9,
 	"Archimedes":    8,
 	"Dozentenfoyer": 5,
+	// Hönggerberg
+	"FusionMeal": 16,
+	"Bellavista": 14,
 }
 
 // Return the URL for the daily offer of the specified mensa
@@ -37,45 +42,69 @@ const EthMensaUrl = "https://ethz.ch/en/campus/erleben/gastronomie-und-einkaufen
 // where to find the menu in the HTML
 const EthMenuElement = "div.basecomponent.image-component--full"
 
-// Return the scraped content
-func scrapeEthMensaPage(mensa string) (string, error) {
-	today := time.Now().Format("2006-01-02")
-
-	// Do not use cache as menu images may not be available before
-	// // Check if the file for today's menu is already cached
-	// fileName := fmt.Sprintf("%s_%s.html", today, mensa)
-	// if _, err := os.Stat(fileName); err == nil {
-	// 	// File exists, read its content
-	// 	content, err := os.ReadFile(fileName)
-	// 	if err != nil {
-	// 		return "", fmt.Errorf("failed to read existing HTML file: %v", err)
-	// 	}
-	// 	return string(content), nil
-	// }
+// how many days scraped pages are kept after their date
+const pageCacheDays = 7
 
-	mensaUrl := EthDailyOfferUrl(mensa, today)
+// Return the directory of the scraped pages, in `DATA_DIR` to survive deploys
+func ethPageCacheDir() string {
+	return utils.DataPath("mensa_pages")
+}
 
-	scrapeEndpoint := fmt.Sprintf("%s?api_key=%s&url=%s&render_js=true", os.Getenv("ABSTRACT_API_URL"),
-		os.Getenv("ABSTRACT_API_KEY"), url.QueryEscape(mensaUrl))
+// Return the cached file name of the scraped content
+func cachedEthMensaPage(mensa string, date string) string {
+	return filepath.Join(ethPageCacheDir(), fmt.Sprintf("%s_%s.html", date, mensa))
+}
 
-	resp, err := http.Get(scrapeEndpoint)
+// Delete the cached pages of days more than `pageCacheDays` ago
+func pruneEthPageCache(logger *utils.BotLogger) {
+	entries, err := os.ReadDir(ethPageCacheDir())
 	if err != nil {
-		return "", err
+		logger.Errorf("Error listing cached pages: %v", err)
+		return
 	}
-	defer resp.Body.Close()
+	oldest := utils.Now().AddDate(0, 0, -pageCacheDays).Format("2006-01-02")
+	for _, entry := range entries {
+		date, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok || !strings.HasSuffix(entry.Name(), ".html") || date >= oldest {
+			continue
+		}
+		if err := os.Remove(filepath.Join(ethPageCacheDir(), entry.Name())); err != nil {
+			logger.Errorf("Error deleting cached page %s: %v", entry.Name(), err)
+		}
+	}
+}
 
-	body, err := io.ReadAll(resp.Body)
+// Return the scraped content of the given date in the format "YYYY-MM-DD"
+// The cached content is only used when the upstream fails, as menu images
+// may not be available in earlier scrapes
+func scrapeEthMensaPage(mensa string, date string, logger *utils.BotLogger) (string, error) {
+	mensaUrl := EthDailyOfferUrl(mensa, date)
+
+	scrapeEndpoint := fmt.Sprintf("%s?api_key=%s&url=%s&render_js=true", os.Getenv("ABSTRACT_API_URL"),
+		os.Getenv("ABSTRACT_API_KEY"), url.QueryEscape(mensaUrl))
+
+	fileName := cachedEthMensaPage(mensa, date)
+	reserve := func() error { return reserveAbstractCall(logger) }
+	body, err := fetchWithRetry(scrapeEndpoint, reserve, logger)
 	if err != nil {
-		return "", err
+		content, cacheErr := os.ReadFile(fileName)
+		if cacheErr != nil {
+			return "", err
+		}
+		logger.Warningf("Failed to scrape %s: %v, using cached content", mensa, err)
+		return string(content), nil
 	}
 
 	// clean up the scraped content
 	htmlContent := cleanScrapeContent(string(body))
-	fileName := fmt.Sprintf("%s_%s.html", today, mensa)
+	if err := os.MkdirAll(ethPageCacheDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create the page cache: %v", err)
+	}
 	err = os.WriteFile(fileName, []byte(htmlContent), 0644)
 	if err != nil {
 		return "", fmt.Errorf("failed to write HTML file: %v", err)
 	}
+	pruneEthPageCache(logger)
 
 	return htmlContent, nil
 }
@@ -126,6 +155,8 @@ func parseEthMenus(htmlContent string) ([]MenuItem, error) {
 				priceText := menuSection.Find(".cp-menu__prices .cp-menu__paragraph").First().Text()
 				item.Price = strings.TrimSpace(priceText)
 
+				parseEthSustainability(menuSection, &item)
+
 				menus = append(menus, item)
 			})
 		}
@@ -134,18 +165,62 @@ func parseEthMenus(htmlContent string) ([]MenuItem, error) {
 	return menus, nil
 }
 
-// Return all eth menus of the given meal type
-func AllEthMenus() ([]MenuItem, error) {
-	var allMenus []MenuItem
-	for mensa := range EthMensaId {
-		menuElement, err := scrapeEthMensaPage(mensa)
-		if err != nil {
-			return nil, err
+// matches climate footprints such as "450 g CO2" or "1.2 kg CO2-eq"
+var co2Pattern = regexp.MustCompile(`(?i)(\d+(?:[.,]\d+)?)\s*(g|kg)\s*CO2`)
+
+// matches the origin of the meat such as "Origin: Switzerland" or "Herkunft Fleisch: Schweiz"
+var originPattern = regexp.MustCompile(`(?i)(?:origin(?: of meat)?|herkunft(?: fleisch)?)\s*:\s*([^.;|]+)`)
+
+// Return the texts of a dish card one by one
+// The text of the whole card joins its elements without separator, e.g. the price with the footprint
+func menuTexts(menuSection *goquery.Selection) []string {
+	var texts []string
+	menuSection.Find("*").AddSelection(menuSection).Contents().Each(func(i int, node *goquery.Selection) {
+		if goquery.NodeName(node) != "#text" {
+			return
+		}
+		if text := strings.TrimSpace(node.Text()); text != "" {
+			texts = append(texts, text)
+		}
+	})
+	return texts
+}
+
+// Parse the climate label, footprint and meat origin of a dish
+func parseEthSustainability(menuSection *goquery.Selection, item *MenuItem) {
+	for _, text := range menuTexts(menuSection) {
+		if match := co2Pattern.FindStringSubmatch(text); match != nil && item.CO2Grams == 0 {
+			amount, err := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", "."), 64)
+			if err == nil {
+				if strings.EqualFold(match[2], "kg") {
+					amount *= 1000
+				}
+				item.CO2Grams = int(amount)
+			}
 		}
-		menus, err := parseEthMenus(menuElement)
-		for i := range menus {
-			menus[i].Location = mensa
+
+		if match := originPattern.FindStringSubmatch(text); match != nil && item.Origin == "" {
+			item.Origin = strings.TrimSpace(match[1])
 		}
+	}
+
+	// climate labels are shown as icons
+	menuSection.Find("img[alt], [title]").EachWithBreak(func(i int, label *goquery.Selection) bool {
+		name := label.AttrOr("alt", label.AttrOr("title", ""))
+		lowerName := strings.ToLower(name)
+		if strings.Contains(lowerName, "climate") || strings.Contains(lowerName, "klima") {
+			item.Climate = strings.TrimSpace(name)
+			return false
+		}
+		return true
+	})
+}
+
+// Return all eth menus of the given date
+func AllEthMenus(date string, logger *utils.BotLogger) ([]MenuItem, error) {
+	var allMenus []MenuItem
+	for mensa := range EthMensaId {
+		menus, err := EthMensaMenus(mensa, date, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -154,6 +229,27 @@ func AllEthMenus() ([]MenuItem, error) {
 	return allMenus, nil
 }
 
+// Return the menus of a single eth mensa on the given date
+func EthMensaMenus(mensa string, date string, logger *utils.BotLogger) ([]MenuItem, error) {
+	menuElement, err := scrapeEthMensaPage(mensa, date, logger)
+	if err != nil {
+		return nil, err
+	}
+	menus, err := parseEthMenus(menuElement)
+	if err != nil {
+		return nil, err
+	}
+	for i := range menus {
+		menus[i].Location = mensa
+		menus[i].Date = date
+	}
+	// subscribers are only notified about changes of today's menus, not of exported or upcoming days
+	if date == utils.Today() {
+		notifyMenuChanges(recordMenuSnapshot(mensa, date, menus, logger), logger)
+	}
+	return menus, nil
+}
+
 // clean the scraped content
 func cleanScrapeContent(rawContent string) string {
 	// Delete everything before <!-- START main content -->
@@ -0,0 +1,79 @@
+package mensa
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	utils "pbaobot/utils"
+)
+
+func TestParseEthMenus(t *testing.T) {
+	content, err := os.ReadFile("testdata/eth_offer_day.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	menus, err := parseEthMenus(cleanScrapeContent(string(content)))
+	if err != nil {
+		t.Fatalf("parseEthMenus() error = %v", err)
+	}
+
+	expected := []MenuItem{
+		{
+			Type: "Lunch", Category: "STREET", Title: "Chicken Teriyaki",
+			Description: "with rice and vegetables. Origin: Switzerland", Price: "CHF 6.20",
+			ImageURL: "https://ethz.ch/images/menu/street.jpg", CO2Grams: 450, Origin: "Switzerland",
+		},
+		{
+			Type: "Lunch", Category: "GARDEN", Title: "Falafel Bowl (Vegan)",
+			Description: "hummus, salad and pita", Price: "CHF 7.00", CO2Grams: 300, Climate: "Climate-friendly",
+		},
+		{
+			Type: "Dinner", Category: "HOME", Title: "Beef Stroganoff",
+			Description: "with spätzli", Price: "CHF 8.00", Origin: "Schweiz",
+		},
+	}
+	if len(menus) != len(expected) {
+		t.Fatalf("parseEthMenus() returned %d menus, want %d: %+v", len(menus), len(expected), menus)
+	}
+	for i := range expected {
+		if menus[i] != expected[i] {
+			t.Errorf("menu %d = %+v, want %+v", i, menus[i], expected[i])
+		}
+	}
+}
+
+func TestPruneEthPageCache(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	previous := utils.SetClock(utils.FixedClock{Time: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)})
+	defer utils.SetClock(previous)
+
+	if err := os.MkdirAll(ethPageCacheDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, date := range []string{"2026-10-01", "2026-10-10", "2026-10-11", "2026-10-18", "2026-10-20"} {
+		if err := os.WriteFile(cachedEthMensaPage("PolyMensa", date), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pruneEthPageCache(utils.NewBotLogger(io.Discard))
+
+	entries, err := os.ReadDir(ethPageCacheDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	expected := []string{"2026-10-11_PolyMensa.html", "2026-10-18_PolyMensa.html", "2026-10-20_PolyMensa.html"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("cached pages = %v, want %v", names, expected)
+	}
+	if filepath.Dir(cachedEthMensaPage("PolyMensa", "2026-10-18")) != filepath.Join(os.Getenv("DATA_DIR"), "mensa_pages") {
+		t.Errorf("pages are not cached in DATA_DIR")
+	}
+}
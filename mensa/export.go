@@ -0,0 +1,103 @@
+package mensa
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	utils "pbaobot/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// usage of the /mensa export command
+const exportUsage = "Export menus with /mensa export [today|week] [csv|json]"
+
+// Return the dates to export in the format "YYYY-MM-DD"
+// A week covers Monday to Friday, the coming week on weekends
+func exportDates(period string, now time.Time) []string {
+	if period == "today" {
+		return []string{now.Format("2006-01-02")}
+	}
+
+	offset := (int(now.Weekday()) + 6) % 7 // days since Monday
+	monday := now.AddDate(0, 0, -offset)
+	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+		monday = monday.AddDate(0, 0, 7)
+	}
+	var dates []string
+	for day := 0; day < 5; day++ {
+		dates = append(dates, monday.AddDate(0, 0, day).Format("2006-01-02"))
+	}
+	return dates
+}
+
+// Return the menus encoded as CSV
+func menusCSV(menus []MenuItem) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write([]string{"Date", "Location", "Type", "Category", "Title", "Description", "Price", "ImageURL",
+		"CO2Grams", "Climate", "Origin"})
+	for _, menu := range menus {
+		writer.Write([]string{menu.Date, menu.Location, menu.Type, menu.Category, menu.Title,
+			menu.Description, menu.Price, menu.ImageURL, strconv.Itoa(menu.CO2Grams), menu.Climate, menu.Origin})
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// Send the menus of today or this week as a CSV or JSON document
+func sendMenuExport(bot *tgbotapi.BotAPI, message *tgbotapi.Message, args []string, logger *utils.BotLogger) {
+	period, format := "today", "csv"
+	for _, arg := range args {
+		switch arg {
+		case "today", "week":
+			period = arg
+		case "csv", "json":
+			format = arg
+		default:
+			msg := tgbotapi.NewMessage(message.Chat.ID, exportUsage)
+			bot.Send(msg)
+			return
+		}
+	}
+
+	dates := exportDates(period, utils.Now())
+	var menus []MenuItem
+	for _, date := range dates {
+		dayMenus, err := AllEthMenus(date, logger)
+		if err != nil {
+			logger.Errorf("Error fetching menus of %s: %v", date, err)
+			msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, I couldn't fetch the menus. Please try again later.")
+			bot.Send(msg)
+			return
+		}
+		menus = append(menus, dayMenus...)
+	}
+
+	var content []byte
+	var err error
+	if format == "json" {
+		content, err = json.MarshalIndent(menus, "", "  ")
+	} else {
+		content, err = menusCSV(menus)
+	}
+	if err != nil {
+		logger.Errorf("Error encoding menus: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to export the menus. Please try again.")
+		bot.Send(msg)
+		return
+	}
+
+	fileName := fmt.Sprintf("menus_%s.%s", dates[0], format)
+	if len(dates) > 1 {
+		fileName = fmt.Sprintf("menus_%s_%s.%s", dates[0], dates[len(dates)-1], format)
+	}
+	document := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{Name: fileName, Bytes: content})
+	if _, err := bot.Send(document); err != nil {
+		logger.Errorf("Error sending document: %v", err)
+	}
+}
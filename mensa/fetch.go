@@ -0,0 +1,178 @@
+package mensa
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	utils "pbaobot/utils"
+)
+
+// maximum number of attempts for a single request
+const maxAttempts = 4
+
+// backoff before the first retry, doubled for each further retry
+const baseBackoff = 1 * time.Second
+
+// upper bound of the backoff between two attempts
+const maxBackoff = 10 * time.Second
+
+// number of consecutive failed requests after which an upstream is considered down
+const breakerThreshold = 3
+
+// how long requests to a down upstream are short-circuited
+const breakerCooldown = 5 * time.Minute
+
+// returned when requests to an upstream are short-circuited
+var errCircuitOpen = errors.New("upstream is down, circuit breaker is open")
+
+// An unexpected HTTP status code returned by an upstream
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// Whether a failed request is worth retrying
+func isTransient(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests ||
+			statusErr.StatusCode == http.StatusRequestTimeout ||
+			statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	// network errors
+	return true
+}
+
+// A circuit breaker stops sending requests to an upstream after repeated failures
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+var (
+	// maps from upstream host to its circuit breaker
+	breakers   = make(map[string]*circuitBreaker)
+	breakersMu sync.Mutex
+)
+
+// Return the circuit breaker of the upstream
+func breakerFor(upstream string) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	breaker, ok := breakers[upstream]
+	if !ok {
+		breaker = &circuitBreaker{}
+		breakers[upstream] = breaker
+	}
+	return breaker
+}
+
+// Whether a request may be sent at time now
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.openUntil)
+}
+
+// Record a successful request
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// Record a failed request at time now, return whether the breaker opened
+func (b *circuitBreaker) failure(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures < breakerThreshold {
+		return false
+	}
+	b.openUntil = now.Add(breakerCooldown)
+	return true
+}
+
+// Return the jittered backoff before the given retry, starting from 1
+func backoff(retry int) time.Duration {
+	delay := baseBackoff << (retry - 1)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	// full jitter
+	return time.Duration(rand.Int63n(int64(delay))) + time.Millisecond
+}
+
+// Send a single GET request and return the body of a successful response
+func fetchOnce(rawURL string) ([]byte, error) {
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		// do not leak the URL, it may contain an API key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
+
+// Send a GET request, retrying transient errors with backoff
+// Requests to an upstream that is down are short-circuited with `errCircuitOpen`
+// If reserve is not nil, it is called before each attempt and its error aborts the request
+func fetchWithRetry(rawURL string, reserve func() error, logger *utils.BotLogger) ([]byte, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %v", err)
+	}
+	upstream := parsedURL.Host
+	breaker := breakerFor(upstream)
+	if !breaker.allow(utils.Now()) {
+		return nil, errCircuitOpen
+	}
+
+	for attempt := 1; ; attempt++ {
+		if reserve != nil {
+			if err := reserve(); err != nil {
+				return nil, err
+			}
+		}
+		body, err := fetchOnce(rawURL)
+		if err == nil {
+			breaker.success()
+			return body, nil
+		}
+
+		if !isTransient(err) || attempt == maxAttempts {
+			if breaker.failure(utils.Now()) {
+				logger.Errorf("Upstream %s is down, skipping requests for %v: %v", upstream, breakerCooldown, err)
+			}
+			return nil, err
+		}
+
+		delay := backoff(attempt)
+		logger.Warningf("Request to %s failed (attempt %d/%d): %v, retrying in %v", upstream, attempt, maxAttempts, err, delay)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,84 @@
+package mensa
+
+import (
+	"path"
+
+	utils "pbaobot/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maps from menu image URL to the Telegram file ID of the uploaded image
+var imageFileIDs = utils.NewJSONFile("MENSA_IMAGES_PATH", "mensa_images.json", make(map[string]string))
+
+// Return the cached file ID of the image URL, if any
+func cachedImageFileID(imageURL string, logger *utils.BotLogger) (string, bool) {
+	var fileID string
+	var ok bool
+	imageFileIDs.View(logger, func(fileIDs map[string]string) {
+		fileID, ok = fileIDs[imageURL]
+	})
+	return fileID, ok
+}
+
+// Cache the file ID of the image URL, an empty file ID removes the entry
+func cacheImageFileID(imageURL string, fileID string, logger *utils.BotLogger) {
+	err := imageFileIDs.Update(logger, func(fileIDs *map[string]string) error {
+		if fileID == "" {
+			delete(*fileIDs, imageURL)
+		} else {
+			(*fileIDs)[imageURL] = fileID
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("Error saving menu image file IDs: %v", err)
+	}
+}
+
+// Return the file ID of the largest size of a sent photo
+func sentPhotoFileID(sent tgbotapi.Message) string {
+	if len(sent.Photo) == 0 {
+		return ""
+	}
+	return sent.Photo[len(sent.Photo)-1].FileID
+}
+
+// Send a photo of the menu image with the caption
+// The image is sent by its cached file ID, by its URL, or downloaded and uploaded
+// if Telegram fails to fetch the URL
+func sendMenuPhoto(bot *tgbotapi.BotAPI, chatID int64, imageURL string, caption string, logger *utils.BotLogger) error {
+	newPhoto := func(file tgbotapi.RequestFileData) tgbotapi.PhotoConfig {
+		photo := tgbotapi.NewPhoto(chatID, file)
+		photo.Caption = caption
+		photo.ParseMode = "Markdown"
+		return photo
+	}
+
+	if fileID, ok := cachedImageFileID(imageURL, logger); ok {
+		_, err := bot.Send(newPhoto(tgbotapi.FileID(fileID)))
+		if err == nil {
+			return nil
+		}
+		logger.Warningf("Error sending cached menu image: %v", err)
+		cacheImageFileID(imageURL, "", logger)
+	}
+
+	sent, err := bot.Send(newPhoto(tgbotapi.FileURL(imageURL)))
+	if err != nil {
+		logger.Warningf("Error sending menu image by URL: %v, uploading it instead", err)
+		image, downloadErr := fetchWithRetry(imageURL, nil, logger)
+		if downloadErr != nil {
+			return downloadErr
+		}
+		sent, err = bot.Send(newPhoto(tgbotapi.FileBytes{Name: path.Base(imageURL), Bytes: image}))
+		if err != nil {
+			return err
+		}
+	}
+
+	if fileID := sentPhotoFileID(sent); fileID != "" {
+		cacheImageFileID(imageURL, fileID, logger)
+	}
+	return nil
+}
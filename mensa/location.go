@@ -0,0 +1,213 @@
+package mensa
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	utils "pbaobot/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Geographic coordinates in degrees
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Maps from ETH mensa location to its coordinates
+var EthMensaCoordinates = map[string]Coordinates{
+	// Zentrum
+	"Clausiusbar":   {Latitude: 47.37810, Longitude: 8.54838},
+	"PolyMensa":     {Latitude: 47.37653, Longitude: 8.54800},
+	"Archimedes":    {Latitude: 47.37838, Longitude: 8.54893},
+	"Dozentenfoyer": {Latitude: 47.37644, Longitude: 8.54768},
+	// Hönggerberg
+	"FusionMeal": {Latitude: 47.40835, Longitude: 8.50771},
+	"Bellavista": {Latitude: 47.40796, Longitude: 8.50795},
+}
+
+// Opening hours of a meal on a weekday, in the format "15:04"
+type OpeningHours struct {
+	Open  string
+	Close string
+}
+
+// Maps from ETH mensa location to the opening hours of each meal type
+// all mensas are closed on weekends
+var EthMensaHours = map[string]map[string]OpeningHours{
+	"Clausiusbar":   {"Lunch": {Open: "11:00", Close: "14:00"}},
+	"PolyMensa":     {"Lunch": {Open: "11:00", Close: "13:30"}, "Dinner": {Open: "17:30", Close: "19:30"}},
+	"Archimedes":    {"Lunch": {Open: "11:00", Close: "13:30"}},
+	"Dozentenfoyer": {"Lunch": {Open: "11:30", Close: "14:00"}},
+	"FusionMeal":    {"Lunch": {Open: "11:00", Close: "13:30"}},
+	"Bellavista":    {"Lunch": {Open: "11:30", Close: "13:30"}},
+}
+
+// meal types in the order they are served during a day
+var mealTypes = []string{"Lunch", "Dinner"}
+
+// average walking speed in meters per minute
+const walkingSpeed = 80.0
+
+// straight-line distances are scaled by this factor to estimate walking distances
+const walkingDetourFactor = 1.3
+
+// An open mensa and its distance from the user
+type nearbyMensa struct {
+	Location string
+	MealType string
+	Hours    OpeningHours
+	Distance float64 // walking distance in meters
+}
+
+// Return the opening and closing time of the hours on the day of t
+func (h OpeningHours) on(t time.Time) (time.Time, time.Time, error) {
+	openTime, err := time.Parse("15:04", h.Open)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	closeTime, err := time.Parse("15:04", h.Close)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	year, month, day := t.Date()
+	openTime = time.Date(year, month, day, openTime.Hour(), openTime.Minute(), 0, 0, t.Location())
+	closeTime = time.Date(year, month, day, closeTime.Hour(), closeTime.Minute(), 0, 0, t.Location())
+	return openTime, closeTime, nil
+}
+
+// Return the meal type served by the mensa at time t, if any
+func openMealAt(mensa string, t time.Time) (string, OpeningHours, bool) {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return "", OpeningHours{}, false
+	}
+	for mealType, hours := range EthMensaHours[mensa] {
+		openTime, closeTime, err := hours.on(t)
+		if err != nil {
+			continue
+		}
+		if !t.Before(openTime) && t.Before(closeTime) {
+			return mealType, hours, true
+		}
+	}
+	return "", OpeningHours{}, false
+}
+
+// Return the next meal type that is still served on or after time t, and its date
+// Meals served today count until the last mensa serving them closes
+func nextMeal(t time.Time) (string, time.Time) {
+	for day := 0; day < 7; day++ {
+		date := t.AddDate(0, 0, day)
+		if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			continue
+		}
+		for _, mealType := range mealTypes {
+			var lastClose time.Time
+			for _, hours := range EthMensaHours {
+				mealHours, ok := hours[mealType]
+				if !ok {
+					continue
+				}
+				_, closeTime, err := mealHours.on(date)
+				if err != nil {
+					continue
+				}
+				if closeTime.After(lastClose) {
+					lastClose = closeTime
+				}
+			}
+			if lastClose.IsZero() || (day == 0 && !t.Before(lastClose)) {
+				continue
+			}
+			return mealType, date
+		}
+	}
+	return mealTypes[0], t
+}
+
+// Return the estimated walking distance in meters between two coordinates
+func walkingDistance(from, to Coordinates) float64 {
+	const earthRadius = 6371000.0 // meters
+	lat1 := from.Latitude * math.Pi / 180
+	lat2 := to.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a)) * walkingDetourFactor
+}
+
+// Return the mensas open at time t sorted by walking distance from the user
+func openMensasNear(user Coordinates, t time.Time) []nearbyMensa {
+	var mensas []nearbyMensa
+	for mensa, coordinates := range EthMensaCoordinates {
+		mealType, hours, ok := openMealAt(mensa, t)
+		if !ok {
+			continue
+		}
+		mensas = append(mensas, nearbyMensa{
+			Location: mensa,
+			MealType: mealType,
+			Hours:    hours,
+			Distance: walkingDistance(user, coordinates),
+		})
+	}
+	sort.Slice(mensas, func(i, j int) bool {
+		return mensas[i].Distance < mensas[j].Distance
+	})
+	return mensas
+}
+
+// Send the menus of the currently open mensas sorted by walking distance
+// from the location shared in the message
+func SendNearestMensaMenus(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	user := Coordinates{
+		Latitude:  message.Location.Latitude,
+		Longitude: message.Location.Longitude,
+	}
+	mensas := openMensasNear(user, utils.Now())
+	if len(mensas) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, no mensa is open right now.")
+		bot.Send(msg)
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString("Open mensas near you:\n")
+	for i, mensa := range mensas {
+		text.WriteString(fmt.Sprintf("%d. %s - %s until %s, %.0f m (~%.0f min walk)\n", i+1, mensa.Location,
+			mensa.MealType, mensa.Hours.Close, mensa.Distance, math.Ceil(mensa.Distance/walkingSpeed)))
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text.String())
+	bot.Send(msg)
+
+	compact := preferencesOf(message.From.ID, logger).Compact
+	for _, mensa := range mensas {
+		menus, err := EthMensaMenus(mensa.Location, utils.Today(), logger)
+		if err != nil {
+			logger.Errorf("Error fetching menus of %s: %v", mensa.Location, err)
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Sorry, I couldn't fetch the menus of %s.", mensa.Location))
+			bot.Send(msg)
+			continue
+		}
+		var mealMenus []MenuItem
+		for _, menu := range menus {
+			if menu.Type == mensa.MealType {
+				mealMenus = append(mealMenus, menu)
+			}
+		}
+		if compact {
+			if len(mealMenus) > 0 {
+				sendMenuSummary(bot, message.Chat.ID, mealMenus, logger)
+			}
+			continue
+		}
+		for _, menu := range mealMenus {
+			sendMenuItem(bot, message.Chat.ID, menu, logger)
+		}
+	}
+}
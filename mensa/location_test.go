@@ -0,0 +1,66 @@
+package mensa
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	utils "pbaobot/utils"
+)
+
+func TestNextMealAfterMidnight(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time // in UTC
+		today    string
+		wantMeal string
+		wantDate string
+	}{
+		// 00:30 in Zurich is still the previous day in UTC
+		{"weekday", time.Date(2026, 10, 19, 22, 30, 0, 0, time.UTC), "2026-10-20", "Lunch", "2026-10-20"},
+		{"weekend", time.Date(2026, 10, 23, 22, 30, 0, 0, time.UTC), "2026-10-24", "Lunch", "2026-10-26"},
+		{"winter time", time.Date(2026, 11, 2, 23, 30, 0, 0, time.UTC), "2026-11-03", "Lunch", "2026-11-03"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := utils.SetClock(utils.FixedClock{Time: tt.now})
+			defer utils.SetClock(previous)
+
+			if today := utils.Today(); today != tt.today {
+				t.Errorf("Today() = %s, want %s", today, tt.today)
+			}
+			if hour := utils.Now().Hour(); hour != 0 {
+				t.Errorf("Now() is at hour %d in Zurich, want 0", hour)
+			}
+			mealType, date := nextMeal(utils.Now())
+			if mealType != tt.wantMeal || date.Format("2006-01-02") != tt.wantDate {
+				t.Errorf("nextMeal() = %s on %s, want %s on %s", mealType, date.Format("2006-01-02"), tt.wantMeal, tt.wantDate)
+			}
+		})
+	}
+}
+
+func TestOpenMensasNear(t *testing.T) {
+	zentrumMensas := []string{"Clausiusbar", "PolyMensa", "Archimedes", "Dozentenfoyer"}
+	hongg := Coordinates{Latitude: 47.40820, Longitude: 8.50760}
+	zentrum := Coordinates{Latitude: 47.37640, Longitude: 8.54780}
+	// a Monday at noon in Zurich
+	lunch := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC).In(utils.Now().Location())
+
+	mensas := openMensasNear(hongg, lunch)
+	if len(mensas) != len(EthMensaCoordinates) {
+		t.Fatalf("openMensasNear() returned %d mensas, want %d", len(mensas), len(EthMensaCoordinates))
+	}
+	if slices.Contains(zentrumMensas, mensas[0].Location) || mensas[0].Distance > 500 {
+		t.Errorf("nearest mensa at Hönggerberg = %s at %.0f m", mensas[0].Location, mensas[0].Distance)
+	}
+
+	mensas = openMensasNear(zentrum, lunch)
+	if !slices.Contains(zentrumMensas, mensas[0].Location) {
+		t.Errorf("nearest mensa at Zentrum = %s", mensas[0].Location)
+	}
+	// Hönggerberg is a few kilometers away from Zentrum
+	if last := mensas[len(mensas)-1]; slices.Contains(zentrumMensas, last.Location) || last.Distance < 3000 {
+		t.Errorf("farthest mensa from Zentrum = %s at %.0f m", last.Location, last.Distance)
+	}
+}
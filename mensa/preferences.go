@@ -0,0 +1,30 @@
+package mensa
+
+import (
+	utils "pbaobot/utils"
+)
+
+// Mensa preferences of a user
+type Preferences struct {
+	Compact bool `json:"compact"` // send menus as a single summary message
+}
+
+// maps from user ID to their preferences
+var userPreferences = utils.NewJSONFile("MENSA_PREFERENCES_PATH", "mensa_preferences.json", make(map[int64]Preferences))
+
+// Return the preferences of a user
+func preferencesOf(userID int64, logger *utils.BotLogger) Preferences {
+	var preferences Preferences
+	userPreferences.View(logger, func(all map[int64]Preferences) {
+		preferences = all[userID]
+	})
+	return preferences
+}
+
+// Store the preferences of a user
+func setPreferences(userID int64, preferences Preferences, logger *utils.BotLogger) error {
+	return userPreferences.Update(logger, func(all *map[int64]Preferences) error {
+		(*all)[userID] = preferences
+		return nil
+	})
+}
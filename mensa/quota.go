@@ -0,0 +1,109 @@
+package mensa
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	utils "pbaobot/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// share of the monthly budget after which a warning is logged
+const budgetWarningRatio = 0.9
+
+// returned when the monthly Abstract API budget is used up
+var errBudgetExceeded = errors.New("monthly Abstract API budget exceeded")
+
+// Number of paid Abstract API calls
+type ApiUsage struct {
+	Daily   map[string]int `json:"daily"`   // maps from "YYYY-MM-DD" to calls
+	Monthly map[string]int `json:"monthly"` // maps from "YYYY-MM" to calls
+}
+
+// A summary of the Abstract API usage
+type ApiUsageReport struct {
+	Today         int `json:"today"`
+	Month         int `json:"month"`
+	MonthlyBudget int `json:"monthly_budget"` // 0 if unlimited
+}
+
+// the Abstract API calls made so far
+var apiUsage = utils.NewJSONFile("ABSTRACT_API_USAGE_PATH", "abstract_api_usage.json",
+	ApiUsage{Daily: make(map[string]int), Monthly: make(map[string]int)})
+
+// Return the configured monthly budget, 0 if unlimited
+func monthlyBudget() int {
+	budget, err := strconv.Atoi(os.Getenv("ABSTRACT_API_MONTHLY_BUDGET"))
+	if err != nil || budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// Account for one Abstract API call
+// Return `errBudgetExceeded` instead if the call would exceed the monthly budget,
+// or an error if the usage cannot be loaded
+func reserveAbstractCall(logger *utils.BotLogger) error {
+	now := utils.Now()
+	day := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+
+	budget := monthlyBudget()
+	counted := false
+	err := apiUsage.Update(logger, func(usage *ApiUsage) error {
+		if budget > 0 && usage.Monthly[month] >= budget {
+			return errBudgetExceeded
+		}
+		counted = true
+		usage.Daily[day]++
+		usage.Monthly[month]++
+		if budget > 0 && usage.Monthly[month] == int(float64(budget)*budgetWarningRatio) {
+			logger.Warningf("Abstract API usage reached %d of the monthly budget of %d calls", usage.Monthly[month], budget)
+		}
+		return nil
+	})
+	if errors.Is(err, errBudgetExceeded) {
+		return err
+	}
+	// the budget cannot be guarded while the usage cannot be loaded
+	if err != nil && !counted {
+		return fmt.Errorf("failed to account Abstract API call: %w", err)
+	}
+	if err != nil {
+		logger.Errorf("Error saving Abstract API usage: %v", err)
+	}
+	return nil
+}
+
+// Return the Abstract API usage of today and this month
+func AbstractApiUsage(logger *utils.BotLogger) ApiUsageReport {
+	now := utils.Now()
+	report := ApiUsageReport{MonthlyBudget: monthlyBudget()}
+	apiUsage.View(logger, func(usage ApiUsage) {
+		report.Today = usage.Daily[now.Format("2006-01-02")]
+		report.Month = usage.Monthly[now.Format("2006-01")]
+	})
+	return report
+}
+
+// Send the Abstract API usage to an admin
+func SendApiUsage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	if !utils.IsAdminUser(message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, only admins can see the API usage.")
+		bot.Send(msg)
+		return
+	}
+
+	report := AbstractApiUsage(logger)
+	budget := "unlimited"
+	if report.MonthlyBudget > 0 {
+		budget = strconv.Itoa(report.MonthlyBudget)
+	}
+	text := fmt.Sprintf("Abstract API calls:\nToday: %d\nThis month: %d\nMonthly budget: %s",
+		report.Today, report.Month, budget)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	bot.Send(msg)
+}
@@ -0,0 +1,44 @@
+package mensa
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	utils "pbaobot/utils"
+)
+
+func TestReserveAbstractCall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	t.Setenv("ABSTRACT_API_USAGE_PATH", path)
+	t.Setenv("ABSTRACT_API_MONTHLY_BUDGET", "2")
+	previous := apiUsage
+	defer func() { apiUsage = previous }()
+	logger := utils.NewBotLogger(io.Discard)
+
+	// an unreadable usage file refuses the call instead of resetting the usage
+	corrupt := []byte(`{"monthly": {`)
+	if err := os.WriteFile(path, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	apiUsage = utils.NewJSONFile("ABSTRACT_API_USAGE_PATH", "unused.json",
+		ApiUsage{Daily: make(map[string]int), Monthly: make(map[string]int)})
+	if err := reserveAbstractCall(logger); err == nil {
+		t.Errorf("reserveAbstractCall() with a corrupt usage file succeeded")
+	}
+	if content, _ := os.ReadFile(path); string(content) != string(corrupt) {
+		t.Errorf("usage file = %q, want it untouched", content)
+	}
+
+	os.Remove(path)
+	for i := 0; i < 2; i++ {
+		if err := reserveAbstractCall(logger); err != nil {
+			t.Errorf("reserveAbstractCall() %d error = %v", i, err)
+		}
+	}
+	if err := reserveAbstractCall(logger); !errors.Is(err, errBudgetExceeded) {
+		t.Errorf("reserveAbstractCall() over the budget = %v, want %v", err, errBudgetExceeded)
+	}
+}
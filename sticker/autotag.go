@@ -0,0 +1,77 @@
+package sticker
+
+import (
+	"fmt"
+	utils "pbaobot/utils"
+	"slices"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Return a pack title as a tag: lower case words without punctuation and bot mentions
+// e.g. "Cute Cats :: @fStikBot" becomes "cute cats"
+func normalizeSetTitle(title string) string {
+	var words []string
+	for _, field := range strings.Fields(norm.NFC.String(title)) {
+		if strings.HasPrefix(field, "@") {
+			continue
+		}
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			if r == '\'' || r == '’' {
+				return -1 // keep "cat's" as one word
+			}
+			return ' '
+		}, field)
+		words = append(words, strings.Fields(word)...)
+	}
+
+	// drop trailing words to fit the tag length
+	for len(words) > 0 && validateTag(strings.Join(words, " ")) == errTagTooLong {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// Return the automatic tags of a sticker: its emoji and the title of its pack
+func autoTagsOf(bot *tgbotapi.BotAPI, sticker *tgbotapi.Sticker, logger *utils.BotLogger) []string {
+	var tags []string
+	if emoji := norm.NFC.String(sticker.Emoji); validateTag(emoji) == nil {
+		tags = append(tags, emoji)
+	}
+	if sticker.SetName != "" {
+		set, err := bot.GetStickerSet(tgbotapi.GetStickerSetConfig{Name: sticker.SetName})
+		if err != nil {
+			logger.Errorf("Error getting sticker set %s: %v", sticker.SetName, err)
+		} else if title := normalizeSetTitle(set.Title); validateTag(title) == nil && !slices.Contains(tags, title) {
+			tags = append(tags, title)
+		}
+	}
+	return tags
+}
+
+// Turn the automatic tags of new stickers on or off
+func SetAutoTags(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	args := strings.Fields(strings.ToLower(message.Text))[1:]
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Turn the automatic emoji and pack tags on or off with /autotag on|off")
+		bot.Send(msg)
+		return
+	}
+
+	preferences := preferencesOf(message.From.ID, logger)
+	preferences.NoAutoTags = args[0] == "off"
+	if err := setPreferences(message.From.ID, preferences, logger); err != nil {
+		logger.Errorf("Error saving sticker preferences: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to save your preference. Please try again.")
+		bot.Send(msg)
+		return
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Automatic tags are %s.", args[0]))
+	bot.Send(msg)
+}
@@ -0,0 +1,389 @@
+package sticker
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	utils "pbaobot/utils"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// version of the backup format, increased on incompatible changes
+const backupVersion = 1
+
+// maximum size of an imported backup
+const maxBackupSize = 20 << 20
+
+// maximum number of tag names listed in an import summary
+const maxListedTags = 20
+
+// usage of the /import command
+const importUsage = "Send me a backup file with the caption /import [merge|replace], " +
+	"then /import confirm to apply it or /import cancel to discard it."
+
+// usage of the command line tool
+const backupUsage = `Usage:
+  app export <file>
+  app import [-replace] [-dry-run] <file>`
+
+// All tags and their stickers
+type Backup struct {
+	Version    int    `json:"version"`
+	ExportedAt string `json:"exported_at"`
+	// maps from tag to the file unique IDs of its stickers to their file IDs
+	Tags map[string]map[string]string `json:"tags"`
+}
+
+// A sticker tagged with a tag
+type tagPair struct {
+	Tag      string
+	UniqueID string
+	FileID   string
+}
+
+// The changes an import makes to the store
+type backupDiff struct {
+	Added       []tagPair
+	Removed     []tagPair
+	NewTags     []string
+	RemovedTags []string
+}
+
+// A backup waiting for the confirmation of the admin who sent it
+type pendingImport struct {
+	Backup  Backup
+	Replace bool
+}
+
+// maps user IDs to the backup they are importing
+var pendingImports = make(map[int64]pendingImport)
+
+// Return all tags of the store as a backup
+func exportTags(ctx context.Context) (Backup, error) {
+	tags, err := store.ListTags(ctx)
+	if err != nil {
+		return Backup{}, err
+	}
+	return Backup{
+		Version:    backupVersion,
+		ExportedAt: utils.Now().Format("2006-01-02T15:04:05Z07:00"),
+		Tags:       tags,
+	}, nil
+}
+
+// Decode and validate a backup
+func parseBackup(content []byte) (Backup, error) {
+	var backup Backup
+	if err := json.Unmarshal(content, &backup); err != nil {
+		return Backup{}, fmt.Errorf("invalid backup: %v", err)
+	}
+	if backup.Version < 1 || backup.Version > backupVersion {
+		return Backup{}, fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+	for tag, stickers := range backup.Tags {
+		if err := validateTag(tag); err != nil {
+			return Backup{}, fmt.Errorf("invalid tag %q: %v", tag, err)
+		}
+		for uniqueID, fileID := range stickers {
+			// unique IDs are used as database keys
+			if uniqueID == "" || strings.ContainsAny(uniqueID, ".#$[]/") || fileID == "" {
+				return Backup{}, fmt.Errorf("invalid sticker %q in tag %q", uniqueID, tag)
+			}
+		}
+	}
+	return backup, nil
+}
+
+// Return the changes needed to merge the backup into the current tags, or to replace them with it
+func diffBackup(current map[string]map[string]string, backup Backup, replace bool) backupDiff {
+	var diff backupDiff
+	for tag, stickers := range backup.Tags {
+		if len(current[tag]) == 0 && len(stickers) > 0 {
+			diff.NewTags = append(diff.NewTags, tag)
+		}
+		for uniqueID, fileID := range stickers {
+			if _, ok := current[tag][uniqueID]; !ok {
+				diff.Added = append(diff.Added, tagPair{Tag: tag, UniqueID: uniqueID, FileID: fileID})
+			}
+		}
+	}
+	if replace {
+		for tag, stickers := range current {
+			if len(backup.Tags[tag]) == 0 {
+				diff.RemovedTags = append(diff.RemovedTags, tag)
+			}
+			for uniqueID, fileID := range stickers {
+				if _, ok := backup.Tags[tag][uniqueID]; !ok {
+					diff.Removed = append(diff.Removed, tagPair{Tag: tag, UniqueID: uniqueID, FileID: fileID})
+				}
+			}
+		}
+	}
+	sort.Strings(diff.NewTags)
+	sort.Strings(diff.RemovedTags)
+	return diff
+}
+
+// Return a list of tag names, shortened if there are too many
+func listTags(tags []string) string {
+	if len(tags) > maxListedTags {
+		return fmt.Sprintf("%s and %d more", strings.Join(tags[:maxListedTags], ", "), len(tags)-maxListedTags)
+	}
+	return strings.Join(tags, ", ")
+}
+
+// Return a human readable summary of the changes
+func (diff backupDiff) String() string {
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 {
+		return "No changes."
+	}
+	lines := []string{
+		fmt.Sprintf("+ %d tagged stickers, %d new tags", len(diff.Added), len(diff.NewTags)),
+		fmt.Sprintf("- %d tagged stickers, %d removed tags", len(diff.Removed), len(diff.RemovedTags)),
+	}
+	if len(diff.NewTags) > 0 {
+		lines = append(lines, "New tags: "+listTags(diff.NewTags))
+	}
+	if len(diff.RemovedTags) > 0 {
+		lines = append(lines, "Removed tags: "+listTags(diff.RemovedTags))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Apply the changes to the store
+func applyBackupDiff(ctx context.Context, diff backupDiff) error {
+	for _, pair := range diff.Removed {
+		if _, err := store.RemoveTag(ctx, pair.UniqueID, pair.Tag); err != nil {
+			return err
+		}
+	}
+
+	// add all tags of a sticker at once
+	stickerTags := make(map[string][]string)
+	fileIDs := make(map[string]string)
+	for _, pair := range diff.Added {
+		stickerTags[pair.UniqueID] = append(stickerTags[pair.UniqueID], pair.Tag)
+		fileIDs[pair.UniqueID] = pair.FileID
+	}
+	for uniqueID, tags := range stickerTags {
+		sort.Strings(tags)
+		if _, err := store.AddTags(ctx, uniqueID, fileIDs[uniqueID], tags); err != nil {
+			return err
+		}
+	}
+	invalidateTagIndex()
+	return nil
+}
+
+// Compute the changes of an import against the current tags
+func importDiff(ctx context.Context, backup Backup, replace bool) (backupDiff, error) {
+	current, err := store.ListTags(ctx)
+	if err != nil {
+		return backupDiff{}, err
+	}
+	return diffBackup(current, backup, replace), nil
+}
+
+// Send all tags as a JSON document to an admin
+func ExportTags(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	if !utils.IsAdminUser(message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, only admins can export the tags.")
+		bot.Send(msg)
+		return
+	}
+
+	backup, err := exportTags(context.Background())
+	var content []byte
+	if err == nil {
+		content, err = json.MarshalIndent(backup, "", "  ")
+	}
+	if err != nil {
+		logger.Errorf("Error exporting tags: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to export the tags. Please try again.")
+		bot.Send(msg)
+		return
+	}
+
+	fileName := fmt.Sprintf("tags_%s.json", utils.Today())
+	document := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{Name: fileName, Bytes: content})
+	document.Caption = fmt.Sprintf("%d tags", len(backup.Tags))
+	if _, err := bot.Send(document); err != nil {
+		logger.Errorf("Error sending document: %v", err)
+	}
+}
+
+// Download the content of a document sent to the bot
+func downloadDocument(bot *tgbotapi.BotAPI, document *tgbotapi.Document) ([]byte, error) {
+	if document.FileSize > maxBackupSize {
+		return nil, fmt.Errorf("file too large: %d bytes", document.FileSize)
+	}
+	url, err := bot.GetFileDirectURL(document.FileID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		// the URL contains the bot token
+		return nil, fmt.Errorf("failed to download file %s", document.FileID)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file %s: status %d", document.FileID, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBackupSize))
+}
+
+// Import tags from a backup document sent by an admin
+// The changes are shown first and only applied after /import confirm
+func ImportTags(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	userID := message.From.ID
+	if !utils.IsAdminUser(userID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, only admins can import tags.")
+		bot.Send(msg)
+		return
+	}
+
+	// the command is in the caption of documents and other media
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	var args []string
+	if fields := strings.Fields(strings.ToLower(text)); len(fields) > 0 {
+		args = fields[1:]
+	}
+	ctx := context.Background()
+
+	if message.Document == nil {
+		pending, ok := pendingImports[userID]
+		switch {
+		case len(args) == 1 && args[0] == "cancel" && ok:
+			delete(pendingImports, userID)
+			msg := tgbotapi.NewMessage(message.Chat.ID, "Import cancelled.")
+			bot.Send(msg)
+		case len(args) == 1 && args[0] == "confirm" && ok:
+			delete(pendingImports, userID)
+			// the tags may have changed since the dry run
+			diff, err := importDiff(ctx, pending.Backup, pending.Replace)
+			if err == nil {
+				err = applyBackupDiff(ctx, diff)
+			}
+			if err != nil {
+				logger.Errorf("Error importing tags: %v", err)
+				msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to import the tags. Please try again.")
+				bot.Send(msg)
+				return
+			}
+			logger.Infof("Imported tags: %d added, %d removed", len(diff.Added), len(diff.Removed))
+			msg := tgbotapi.NewMessage(message.Chat.ID, "Import done.\n"+diff.String())
+			bot.Send(msg)
+		default:
+			msg := tgbotapi.NewMessage(message.Chat.ID, importUsage)
+			bot.Send(msg)
+		}
+		return
+	}
+
+	replace := false
+	if len(args) > 1 || (len(args) == 1 && args[0] != "merge" && args[0] != "replace") {
+		msg := tgbotapi.NewMessage(message.Chat.ID, importUsage)
+		bot.Send(msg)
+		return
+	} else if len(args) == 1 {
+		replace = args[0] == "replace"
+	}
+
+	content, err := downloadDocument(bot, message.Document)
+	if err != nil {
+		logger.Errorf("Error downloading backup: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to download the file. Please try again.")
+		bot.Send(msg)
+		return
+	}
+	backup, err := parseBackup(content)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Sorry, but the file is not a valid backup: %v", err))
+		bot.Send(msg)
+		return
+	}
+	diff, err := importDiff(ctx, backup, replace)
+	if err != nil {
+		logger.Errorf("Error comparing tags: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to read the current tags. Please try again.")
+		bot.Send(msg)
+		return
+	}
+
+	pendingImports[userID] = pendingImport{Backup: backup, Replace: replace}
+	mode := "merge"
+	if replace {
+		mode = "replace"
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Dry run of the %s import:\n%s\n\n"+
+		"Send /import confirm to apply it or /import cancel to discard it.", mode, diff))
+	bot.Send(msg)
+}
+
+// Run the backup command line tool with the arguments after the program name
+func RunBackupCommand(args []string, logger *utils.BotLogger) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", backupUsage)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "export":
+		if len(args) != 2 {
+			return fmt.Errorf("missing file\n%s", backupUsage)
+		}
+		backup, err := exportTags(ctx)
+		if err != nil {
+			return err
+		}
+		if err := utils.SaveJSON(args[1], backup); err != nil {
+			return err
+		}
+		logger.Infof("Exported %d tags to %s", len(backup.Tags), args[1])
+		return nil
+	case "import":
+		flags := flag.NewFlagSet("import", flag.ContinueOnError)
+		replace := flags.Bool("replace", false, "remove the tags missing from the backup")
+		dryRun := flags.Bool("dry-run", false, "only show the changes")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("missing file\n%s", backupUsage)
+		}
+		content, err := os.ReadFile(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		backup, err := parseBackup(content)
+		if err != nil {
+			return err
+		}
+		diff, err := importDiff(ctx, backup, *replace)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Changes of the import:\n%s", diff)
+		if *dryRun {
+			return nil
+		}
+		if err := applyBackupDiff(ctx, diff); err != nil {
+			return err
+		}
+		logger.Infof("Imported %s", flags.Arg(0))
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], backupUsage)
+	}
+}
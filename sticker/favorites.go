@@ -0,0 +1,93 @@
+package sticker
+
+import (
+	utils "pbaobot/utils"
+	"slices"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maximum number of recently used stickers kept per user
+const maxRecentStickers = 20
+
+// A sticker saved for a user
+type savedSticker struct {
+	UniqueID string `json:"unique_id"`
+	FileID   string `json:"file_id"`
+}
+
+// Return the list with the sticker moved to the front, keeping at most `limit` stickers if positive
+func pushSticker(list []savedSticker, sticker savedSticker, limit int) []savedSticker {
+	stickers := []savedSticker{sticker}
+	for _, saved := range list {
+		if saved.UniqueID != sticker.UniqueID {
+			stickers = append(stickers, saved)
+		}
+	}
+	if limit > 0 && len(stickers) > limit {
+		stickers = stickers[:limit]
+	}
+	return stickers
+}
+
+// Return the favorite stickers of a user followed by their recently used ones, for an empty inline query
+func personalStickers(userID int64, logger *utils.BotLogger) []searchResult {
+	favorites := preferencesOf(userID, logger).Favorites
+
+	var recent []savedSticker
+	usage.View(logger, func(usage stickerUsage) {
+		recent = slices.Clone(usage.Recent[userID])
+	})
+
+	var results []searchResult
+	seen := make(map[string]bool)
+	for _, saved := range slices.Concat(favorites, recent) {
+		if seen[saved.UniqueID] {
+			continue
+		}
+		seen[saved.UniqueID] = true
+		results = append(results, searchResult{UniqueID: saved.UniqueID, FileID: saved.FileID, order: len(results)})
+	}
+	return results
+}
+
+// Star or unstar the last sticker the user sent, and stop tagging it
+func ToggleFavorite(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	userID := message.From.ID
+	sticker, ok := userLastSticker[userID]
+	if !ok {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Send me a sticker first, then /fav to star it.")
+		bot.Send(msg)
+		return
+	}
+
+	preferences := preferencesOf(userID, logger)
+	starred := !slices.ContainsFunc(preferences.Favorites, func(saved savedSticker) bool {
+		return saved.UniqueID == sticker.FileUniqueID
+	})
+	if starred {
+		preferences.Favorites = pushSticker(preferences.Favorites, savedSticker{UniqueID: sticker.FileUniqueID, FileID: sticker.FileID}, 0)
+	} else {
+		preferences.Favorites = slices.DeleteFunc(slices.Clone(preferences.Favorites), func(saved savedSticker) bool {
+			return saved.UniqueID == sticker.FileUniqueID
+		})
+	}
+	if err := setPreferences(userID, preferences, logger); err != nil {
+		logger.Errorf("Error saving sticker preferences: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to save your favorites. Please try again.")
+		bot.Send(msg)
+		return
+	}
+
+	// the sticker was only sent to be starred, the next message is not a tag
+	if current, ok := userCurrentSticker[userID]; ok && current.FileUniqueID == sticker.FileUniqueID {
+		resetUserState(userID)
+	}
+
+	text := "Sticker added to your favorites, find it with an empty inline query."
+	if !starred {
+		text = "Sticker removed from your favorites."
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	bot.Send(msg)
+}
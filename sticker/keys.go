@@ -0,0 +1,68 @@
+package sticker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maximum number of characters of a tag
+const maxTagLength = 64
+
+var (
+	errEmptyTag   = errors.New("tag is empty")
+	errTagTooLong = fmt.Errorf("tag is longer than %d characters", maxTagLength)
+)
+
+// Whether the byte must be escaped in a Firebase key
+// `%` is escaped as well to keep the encoding reversible
+func isReservedKeyByte(b byte) bool {
+	return strings.IndexByte("%.#$[]/", b) >= 0 || b < 0x20 || b == 0x7f
+}
+
+// Encode a tag as a Firebase key by percent-encoding the reserved characters
+func encodeTagKey(tag string) string {
+	var key strings.Builder
+	for i := 0; i < len(tag); i++ {
+		if isReservedKeyByte(tag[i]) {
+			key.WriteString(fmt.Sprintf("%%%02X", tag[i]))
+		} else {
+			key.WriteByte(tag[i])
+		}
+	}
+	return key.String()
+}
+
+// Decode a Firebase key back to its tag
+func decodeTagKey(key string) string {
+	var tag strings.Builder
+	for i := 0; i < len(key); i++ {
+		if key[i] == '%' && i+2 < len(key) {
+			if b, err := strconv.ParseUint(key[i+1:i+3], 16, 8); err == nil {
+				tag.WriteByte(byte(b))
+				i += 2
+				continue
+			}
+		}
+		tag.WriteByte(key[i])
+	}
+	return tag.String()
+}
+
+// Return the database path of a tag
+func tagPath(tag string) string {
+	return "tags/" + encodeTagKey(tag)
+}
+
+// Check whether a tag can be stored
+func validateTag(tag string) error {
+	if strings.TrimSpace(tag) == "" {
+		return errEmptyTag
+	}
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return errTagTooLong
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	utils "pbaobot/utils"
+	"slices"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Whether the key is an index of a list, Firebase returns lists with gaps as objects
+// File unique IDs are never plain numbers
+func isListIndex(key string) bool {
+	_, err := strconv.Atoi(key)
+	return err == nil
+}
+
+// Collect the file ID lists of a tag node stored with a raw key
+// Tags containing a slash were stored as nested nodes, e.g. `tags/a/b` for "a/b"
+// Return the file IDs of each tag, keyed by their list index
+func collectTagLists(tag string, node interface{}, lists map[string]map[string]string) {
+	add := func(index string, fileID string) {
+		if lists[tag] == nil {
+			lists[tag] = make(map[string]string)
+		}
+		lists[tag][index] = fileID
+	}
+	switch node := node.(type) {
+	case []interface{}:
+		for i, item := range node {
+			if fileID, ok := item.(string); ok {
+				add(strconv.Itoa(i), fileID)
+			}
+		}
+	case map[string]interface{}:
+		for key, child := range node {
+			if fileID, ok := child.(string); ok {
+				// other strings are stickers which are already migrated
+				if isListIndex(key) {
+					add(key, fileID)
+				}
+				continue
+			}
+			collectTagLists(tag+"/"+key, child, lists)
+		}
+	}
+}
+
+// Migrate tags stored as lists of file IDs to maps from file unique ID to file ID,
+// dropping duplicates and adding the migrated stickers to the reverse index
+// Tags which are already maps are left untouched, other stores have nothing to migrate
+// Migrations run at startup before any update is handled, so they do not need transactions
+func MigrateTags(bot *tgbotapi.BotAPI, logger *utils.BotLogger) error {
+	fs, ok := store.(*firebaseStore)
+	if !ok {
+		return nil
+	}
+	ctx := context.Background()
+	var rawTags map[string]interface{}
+	if err := fs.client.NewRef("tags").Get(ctx, &rawTags); err != nil {
+		return err
+	}
+
+	// keys of list nodes predate the key encoding, so they are the raw tags
+	lists := make(map[string]map[string]string)
+	for key, node := range rawTags {
+		collectTagLists(key, node, lists)
+	}
+	if len(lists) == 0 {
+		return nil
+	}
+
+	updates := make(map[string]interface{})
+	// maps from file unique ID to the migrated tags of the sticker
+	migratedTags := make(map[string][]string)
+	for tag, fileIDs := range lists {
+		stickers := make(map[string]string)
+		for index, fileID := range fileIDs {
+			// write single children, a tag may have nested tags below it
+			updates["tags/"+tag+"/"+index] = nil
+			if fileID == "" {
+				continue
+			}
+			file, err := bot.GetFile(tgbotapi.FileConfig{FileID: fileID})
+			uniqueID := file.FileUniqueID
+			if err != nil || uniqueID == "" {
+				// file IDs are valid keys, keep the sticker rather than losing it
+				logger.Errorf("Error getting file of sticker %s: %v", fileID, err)
+				uniqueID = fileID
+			}
+			stickers[uniqueID] = fileID
+			if !slices.Contains(migratedTags[uniqueID], tag) {
+				migratedTags[uniqueID] = append(migratedTags[uniqueID], tag)
+			}
+		}
+		// nested tags stay nested, `MigrateTagKeys` flattens them
+		for uniqueID, fileID := range stickers {
+			updates["tags/"+tag+"/"+uniqueID] = fileID
+		}
+		logger.Infof("Migrating tag %s: %d stickers, %d duplicates", tag, len(stickers), len(fileIDs)-len(stickers))
+	}
+
+	for uniqueID, tags := range migratedTags {
+		for _, tag := range tags {
+			updates[stickerTagPath(uniqueID, tag)] = true
+		}
+	}
+
+	if err := fs.client.NewRef("").Update(ctx, updates); err != nil {
+		return err
+	}
+	invalidateTagIndex()
+	return nil
+}
+
+// Collect the stickers of a tag node stored with a raw key
+// Tags containing a slash were stored as nested nodes, which are flattened back
+func flattenTagNode(tag string, node interface{}, stickersByTag map[string]map[string]string) error {
+	children, ok := node.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unexpected node of tag %s, migrate the stickers first", tag)
+	}
+	for key, child := range children {
+		if fileID, ok := child.(string); ok {
+			if stickersByTag[tag] == nil {
+				stickersByTag[tag] = make(map[string]string)
+			}
+			stickersByTag[tag][key] = fileID
+			continue
+		}
+		if err := flattenTagNode(tag+"/"+key, child, stickersByTag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Re-key tags stored with raw keys by their encoded keys, once
+func MigrateTagKeys(logger *utils.BotLogger) error {
+	fs, ok := store.(*firebaseStore)
+	if !ok {
+		return nil
+	}
+	ctx := context.Background()
+	var encoded bool
+	if err := fs.client.NewRef("meta/tag_keys_encoded").Get(ctx, &encoded); err != nil {
+		return err
+	}
+	if encoded {
+		return nil
+	}
+
+	var rawTags map[string]interface{}
+	if err := fs.client.NewRef("tags").Get(ctx, &rawTags); err != nil {
+		return err
+	}
+
+	// maps from encoded key to the stickers of the tag
+	encodedTags := make(map[string]map[string]string)
+	for key, node := range rawTags {
+		stickersByTag := make(map[string]map[string]string)
+		if err := flattenTagNode(key, node, stickersByTag); err != nil {
+			return err
+		}
+		for tag, stickers := range stickersByTag {
+			encodedKey := encodeTagKey(tag)
+			if encodedTags[encodedKey] == nil {
+				encodedTags[encodedKey] = make(map[string]string)
+			}
+			for uniqueID, fileID := range stickers {
+				encodedTags[encodedKey][uniqueID] = fileID
+			}
+			if encodedKey != key {
+				logger.Infof("Migrating tag %s to key %s", tag, encodedKey)
+			}
+		}
+	}
+
+	updates := map[string]interface{}{"meta/tag_keys_encoded": true}
+	for key := range rawTags {
+		if _, ok := encodedTags[key]; !ok {
+			updates["tags/"+key] = nil
+		}
+	}
+	for key, stickers := range encodedTags {
+		updates["tags/"+key] = stickers
+	}
+
+	if err := fs.client.NewRef("").Update(ctx, updates); err != nil {
+		return err
+	}
+	invalidateTagIndex()
+	return nil
+}
+
+// Convert reverse index entries stored as lists of tags to maps from encoded tag to true
+// Entries which are already maps are left untouched
+func MigrateStickerIndex(logger *utils.BotLogger) error {
+	fs, ok := store.(*firebaseStore)
+	if !ok {
+		return nil
+	}
+	ctx := context.Background()
+	var rawStickers map[string]interface{}
+	if err := fs.client.NewRef("stickers").Get(ctx, &rawStickers); err != nil {
+		return err
+	}
+
+	updates := make(map[string]interface{})
+	for uniqueID, node := range rawStickers {
+		tags := make(map[string]bool)
+		migrated := false
+		switch node := node.(type) {
+		case []interface{}:
+			for _, item := range node {
+				if tag, ok := item.(string); ok {
+					tags[encodeTagKey(tag)] = true
+				}
+			}
+			migrated = true
+		case map[string]interface{}:
+			for key, child := range node {
+				// list items are tags, Firebase returns lists with gaps as objects
+				if tag, ok := child.(string); ok {
+					tags[encodeTagKey(tag)] = true
+					migrated = true
+				} else {
+					tags[key] = true
+				}
+			}
+		}
+		if migrated {
+			updates["stickers/"+uniqueID] = tags
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	logger.Infof("Migrating the reverse index of %d stickers", len(updates))
+	return fs.client.NewRef("").Update(ctx, updates)
+}
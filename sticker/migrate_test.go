@@ -0,0 +1,51 @@
+package sticker
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestCollectTagLists(t *testing.T) {
+	rawTags := map[string]interface{}{
+		// "a/b" stored as a nested list before tag keys were encoded
+		"a": map[string]interface{}{"b": []interface{}{"f1", "f2"}},
+		"c": []interface{}{"f3"},
+		// a list with gaps and a nested tag "d/e"
+		"d": map[string]interface{}{"2": "f4", "e": []interface{}{"f5"}},
+		// already migrated
+		"x": map[string]interface{}{"AgADx": "f6"},
+	}
+	lists := make(map[string]map[string]string)
+	for key, node := range rawTags {
+		collectTagLists(key, node, lists)
+	}
+
+	expected := map[string]map[string]string{
+		"a/b": {"0": "f1", "1": "f2"},
+		"c":   {"0": "f3"},
+		"d":   {"2": "f4"},
+		"d/e": {"0": "f5"},
+	}
+	if !maps.EqualFunc(lists, expected, maps.Equal) {
+		t.Errorf("collectTagLists() = %v, want %v", lists, expected)
+	}
+}
+
+func TestFlattenTagNode(t *testing.T) {
+	node := map[string]interface{}{
+		"AgADa": "f1",
+		"b":     map[string]interface{}{"AgADb": "f2"},
+	}
+	stickersByTag := make(map[string]map[string]string)
+	if err := flattenTagNode("a", node, stickersByTag); err != nil {
+		t.Fatalf("flattenTagNode() error = %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"a":   {"AgADa": "f1"},
+		"a/b": {"AgADb": "f2"},
+	}
+	if !maps.EqualFunc(stickersByTag, expected, maps.Equal) {
+		t.Errorf("flattenTagNode() = %v, want %v", stickersByTag, expected)
+	}
+}
@@ -0,0 +1,91 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	utils "pbaobot/utils"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// prefix of the callback data of the button to tag a whole pack, followed by the file unique ID of the sticker
+const packCallbackPrefix = "pack:"
+
+// minimum time between two updates of the progress message
+const packProgressInterval = 2 * time.Second
+
+// Return an inline keyboard with a button to tag the whole pack of the sticker
+func packKeyboard(sticker *tgbotapi.Sticker) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Tag whole pack", packCallbackPrefix+sticker.FileUniqueID)))
+}
+
+// Switch to tagging the whole pack of the sticker the user is currently tagging
+func handlePackCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, logger *utils.BotLogger) {
+	userID := query.From.ID
+	sticker, ok := userCurrentSticker[userID]
+	if userStates[userID] != TAG_STATE || !ok || sticker.SetName == "" ||
+		sticker.FileUniqueID != strings.TrimPrefix(query.Data, packCallbackPrefix) {
+		bot.Request(tgbotapi.NewCallback(query.ID, "Send me the sticker again to tag its pack."))
+		return
+	}
+
+	userTagPack[userID] = true
+	bot.Request(tgbotapi.NewCallback(query.ID, "Tags will be added to the whole pack."))
+	if query.Message != nil {
+		edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+			"Send me tags for all stickers of this pack separated by commas or spaces, "+
+				"then /done to save them or /abort to cancel.")
+		if _, err := bot.Send(edit); err != nil {
+			logger.Errorf("Error updating message: %v", err)
+		}
+	}
+}
+
+// Add tags to every sticker of a set, reporting the progress in a message
+func tagStickerSet(bot *tgbotapi.BotAPI, chatID int64, setName string, tags []string, logger *utils.BotLogger) {
+	set, err := bot.GetStickerSet(tgbotapi.GetStickerSetConfig{Name: setName})
+	if err != nil {
+		logger.Errorf("Error getting sticker set %s: %v", setName, err)
+		msg := tgbotapi.NewMessage(chatID, "Sorry, but it failed to get the sticker pack. Please try again.")
+		bot.Send(msg)
+		return
+	}
+
+	total := len(set.Stickers)
+	progress, err := bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Tagging pack %s: 0/%d", set.Title, total)))
+	if err != nil {
+		logger.Errorf("Error sending progress: %v", err)
+	}
+
+	ctx := context.Background()
+	failed := 0
+	lastUpdate := time.Now()
+	for i, sticker := range set.Stickers {
+		if _, err := store.AddTags(ctx, sticker.FileUniqueID, sticker.FileID, tags); err != nil {
+			logger.Errorf("Error tagging sticker %s: %v", sticker.FileUniqueID, err)
+			failed++
+		}
+		if progress.MessageID != 0 && i+1 < total && time.Since(lastUpdate) >= packProgressInterval {
+			edit := tgbotapi.NewEditMessageText(chatID, progress.MessageID,
+				fmt.Sprintf("Tagging pack %s: %d/%d", set.Title, i+1, total))
+			bot.Send(edit)
+			lastUpdate = time.Now()
+		}
+	}
+	invalidateTagIndex()
+	logger.Infof("Tagged %d stickers of set %s with %s", total-failed, setName, strings.Join(tags, ", "))
+
+	text := fmt.Sprintf("Tags %s added to %d stickers of pack %s.", strings.Join(tags, ", "), total-failed, set.Title)
+	if failed > 0 {
+		text += fmt.Sprintf("\nSorry, but it failed to tag %d stickers. Please try again.", failed)
+	}
+	if progress.MessageID != 0 {
+		if _, err := bot.Send(tgbotapi.NewEditMessageText(chatID, progress.MessageID, text)); err == nil {
+			return
+		}
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+}
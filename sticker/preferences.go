@@ -0,0 +1,31 @@
+package sticker
+
+import (
+	utils "pbaobot/utils"
+)
+
+// Sticker preferences of a user
+type Preferences struct {
+	NoAutoTags bool           `json:"no_auto_tags"`        // do not tag new stickers with their emoji and pack title
+	Favorites  []savedSticker `json:"favorites,omitempty"` // starred stickers, most recently starred first
+}
+
+// maps from user ID to their preferences
+var userPreferences = utils.NewJSONFile("STICKER_PREFERENCES_PATH", "sticker_preferences.json", make(map[int64]Preferences))
+
+// Return the preferences of a user
+func preferencesOf(userID int64, logger *utils.BotLogger) Preferences {
+	var preferences Preferences
+	userPreferences.View(logger, func(all map[int64]Preferences) {
+		preferences = all[userID]
+	})
+	return preferences
+}
+
+// Store the preferences of a user
+func setPreferences(userID int64, preferences Preferences, logger *utils.BotLogger) error {
+	return userPreferences.Update(logger, func(all *map[int64]Preferences) error {
+		(*all)[userID] = preferences
+		return nil
+	})
+}
@@ -0,0 +1,101 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	utils "pbaobot/utils"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Move all stickers from a tag to another, dropping duplicates
+// Return the number of moved stickers
+func moveTag(ctx context.Context, from string, to string) (int, error) {
+	moved, err := store.RenameTag(ctx, from, to)
+	if moved > 0 {
+		invalidateTagIndex()
+	}
+	return moved, err
+}
+
+// Parse the two tags of a command, separated by commas if any, otherwise by spaces
+func parseTagPair(text string) (string, string, bool) {
+	parts := strings.SplitN(text, " ", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	tags := parseTags(parts[1])
+	if len(tags) != 2 || tags[0] == tags[1] || validateTag(tags[0]) != nil || validateTag(tags[1]) != nil {
+		return "", "", false
+	}
+	return tags[0], tags[1], true
+}
+
+// Rename a tag, the new tag must not exist yet
+func RenameTag(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	oldTag, newTag, ok := parseTagPair(message.Text)
+	if !ok {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Rename a tag with /rename <old> <new>")
+		bot.Send(msg)
+		return
+	}
+
+	ctx := context.Background()
+	existing, err := store.TagStickers(ctx, newTag)
+	if err != nil {
+		logger.Errorf("Failed to get tag %s: %v", newTag, err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to rename the tag. Please try again.")
+		bot.Send(msg)
+		return
+	}
+	if len(existing) > 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Tag %s already exists, use /merge %s %s instead.", newTag, oldTag, newTag))
+		bot.Send(msg)
+		return
+	}
+
+	moved, err := moveTag(ctx, oldTag, newTag)
+	if err != nil {
+		logger.Errorf("Failed to rename tag %s: %v", oldTag, err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to rename the tag. Please try again.")
+		bot.Send(msg)
+		return
+	}
+	if moved == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Tag %s does not exist.", oldTag))
+		bot.Send(msg)
+		return
+	}
+
+	logger.Infof("Renamed tag %s to %s", oldTag, newTag)
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Renamed tag %s to %s (%d stickers).", oldTag, newTag, moved))
+	bot.Send(msg)
+}
+
+// Merge the stickers of the first tag into the second tag
+func MergeTags(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	fromTag, toTag, ok := parseTagPair(message.Text)
+	if !ok {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Merge a tag into another with /merge <from> <into>")
+		bot.Send(msg)
+		return
+	}
+
+	moved, err := moveTag(context.Background(), fromTag, toTag)
+	if err != nil {
+		logger.Errorf("Failed to merge tag %s into %s: %v", fromTag, toTag, err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to merge the tags. Please try again.")
+		bot.Send(msg)
+		return
+	}
+	if moved == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Tag %s does not exist.", fromTag))
+		bot.Send(msg)
+		return
+	}
+
+	logger.Infof("Merged tag %s into %s", fromTag, toTag)
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Merged tag %s into %s (%d stickers).", fromTag, toTag, moved))
+	bot.Send(msg)
+}
@@ -0,0 +1,231 @@
+package sticker
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// how long the tag index is used before it is reloaded
+const tagIndexTTL = 1 * time.Minute
+
+// match qualities, lower is better
+const (
+	exactMatch  = 0
+	prefixMatch = 1
+	fuzzyMatch  = 2 // plus the edit distance
+)
+
+var (
+	// maps from tag to the file unique IDs of its stickers to their file IDs
+	tagIndex         map[string]map[string]string
+	tagIndexLoadedAt time.Time
+	tagIndexMu       sync.Mutex
+	caseFolder       = cases.Fold()
+)
+
+// A sticker matched by a search with its match quality
+type searchResult struct {
+	UniqueID string
+	FileID   string
+	Score    int
+	Tags     []string // the tags matched by the query
+	order    int      // position of the first match, to keep insertion order among equal scores
+}
+
+// Mark the tag index as stale after a tag is changed
+func invalidateTagIndex() {
+	tagIndexMu.Lock()
+	defer tagIndexMu.Unlock()
+	tagIndexLoadedAt = time.Time{}
+}
+
+// Return the tag index, reloading it if it is stale
+func loadTagIndex(ctx context.Context) (map[string]map[string]string, error) {
+	tagIndexMu.Lock()
+	defer tagIndexMu.Unlock()
+	if tagIndex != nil && time.Since(tagIndexLoadedAt) < tagIndexTTL {
+		return tagIndex, nil
+	}
+
+	tags, err := store.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tagIndex = tags
+	tagIndexLoadedAt = time.Now()
+	return tagIndex, nil
+}
+
+// Return the case folded and normalized text
+func foldText(text string) string {
+	return caseFolder.String(norm.NFC.String(text))
+}
+
+// Split a folded tag into the words it can be matched by
+func tagWords(tag string) []string {
+	words := strings.FieldsFunc(tag, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	if len(words) != 1 || words[0] != tag {
+		words = append(words, tag)
+	}
+	return words
+}
+
+// Return the maximum edit distance tolerated for a search term
+func maxTypos(term string) int {
+	switch length := len([]rune(term)); {
+	case length < 3:
+		return 0
+	case length < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Return the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// Return how well a folded search term matches a folded tag, and whether it matches at all
+func matchTerm(term string, tag string) (int, bool) {
+	best, matched := 0, false
+	for _, word := range tagWords(tag) {
+		score := -1
+		switch {
+		case word == term:
+			score = exactMatch
+		case strings.HasPrefix(word, term):
+			score = prefixMatch
+		default:
+			// compare with the word and its prefix of the same length to tolerate typos while typing
+			distance := levenshtein(term, word)
+			if runes := []rune(word); len(runes) > len([]rune(term)) {
+				distance = min(distance, levenshtein(term, string(runes[:len([]rune(term))])))
+			}
+			if distance <= maxTypos(term) {
+				score = fuzzyMatch + distance
+			}
+		}
+		if score >= 0 && (!matched || score < best) {
+			best, matched = score, true
+		}
+	}
+	return best, matched
+}
+
+// Return the stickers whose tags match every term of the query, best matches first
+func searchStickers(ctx context.Context, query string) ([]searchResult, error) {
+	terms := strings.Fields(foldText(query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	index, err := loadTagIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(index))
+	for tag := range index {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	// the best score of each sticker for each term
+	var scores map[string]int
+	order := make(map[string]int)
+	fileIDs := make(map[string]string)
+	matchedTags := make(map[string][]string)
+	for i, term := range terms {
+		type tagMatch struct {
+			tag   string
+			score int
+		}
+		var matches []tagMatch
+		for _, tag := range tags {
+			if score, ok := matchTerm(term, foldText(tag)); ok {
+				matches = append(matches, tagMatch{tag: tag, score: score})
+			}
+		}
+		sort.SliceStable(matches, func(a, b int) bool {
+			return matches[a].score < matches[b].score
+		})
+
+		termScores := make(map[string]int)
+		for _, match := range matches {
+			stickers := index[match.tag]
+			uniqueIDs := make([]string, 0, len(stickers))
+			for uniqueID, fileID := range stickers {
+				uniqueIDs = append(uniqueIDs, uniqueID)
+				// keep any file ID, they are the same across tags
+				fileIDs[uniqueID] = fileID
+			}
+			sort.Strings(uniqueIDs)
+			for _, uniqueID := range uniqueIDs {
+				if !slices.Contains(matchedTags[uniqueID], match.tag) {
+					matchedTags[uniqueID] = append(matchedTags[uniqueID], match.tag)
+				}
+				if score, ok := termScores[uniqueID]; !ok || match.score < score {
+					termScores[uniqueID] = match.score
+				}
+				if _, ok := order[uniqueID]; !ok {
+					order[uniqueID] = len(order)
+				}
+			}
+		}
+
+		// AND semantics: keep the stickers matching all terms so far
+		if i == 0 {
+			scores = termScores
+			continue
+		}
+		for uniqueID, score := range scores {
+			termScore, ok := termScores[uniqueID]
+			if !ok {
+				delete(scores, uniqueID)
+				continue
+			}
+			scores[uniqueID] = score + termScore
+		}
+	}
+
+	results := make([]searchResult, 0, len(scores))
+	for uniqueID, score := range scores {
+		results = append(results, searchResult{UniqueID: uniqueID, FileID: fileIDs[uniqueID], Score: score,
+			Tags: matchedTags[uniqueID], order: order[uniqueID]})
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score < results[b].Score
+		}
+		return results[a].order < results[b].order
+	})
+	return results, nil
+}
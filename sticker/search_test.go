@@ -0,0 +1,35 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSearchStickersBroadPrefix(t *testing.T) {
+	const tags = 30
+	previous := store
+	defer func() {
+		store = previous
+		invalidateTagIndex()
+	}()
+	store = newMemoryStore()
+	invalidateTagIndex()
+
+	ctx := context.Background()
+	for i := 0; i < tags; i++ {
+		uniqueID := fmt.Sprintf("sticker%02d", i)
+		if _, err := store.AddTags(ctx, uniqueID, "file"+uniqueID, []string{fmt.Sprintf("cat%02d", i)}); err != nil {
+			t.Fatalf("AddTags() error = %v", err)
+		}
+	}
+
+	// every tag starting with the typed prefix is matched
+	results, err := searchStickers(ctx, "cat")
+	if err != nil {
+		t.Fatalf("searchStickers() error = %v", err)
+	}
+	if len(results) != tags {
+		t.Errorf("searchStickers(cat) returned %d stickers, want %d", len(results), tags)
+	}
+}
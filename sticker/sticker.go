@@ -3,26 +3,29 @@ package sticker
 import (
 	"context"
 	"fmt"
-	"os"
 	utils "pbaobot/utils"
+	"slices"
+	"strconv"
 	"strings"
 
-	firebase "firebase.google.com/go/v4"
-	"firebase.google.com/go/v4/db"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
 	"golang.org/x/text/unicode/norm"
-	"google.golang.org/api/iterator"
-	"google.golang.org/api/option"
 )
 
 var (
-	firebaseApp *firebase.App
-	firebaseDB  *db.Client
 	// maps user IDs to their current state: `initialState` or `tagState`
 	userStates map[int64]string
-	// maps user IDs to the file ID of the sticker they are currently tagging
-	userCurrentSticker map[int64]string
+	// maps user IDs to the sticker they are currently tagging
+	userCurrentSticker map[int64]tgbotapi.Sticker
+	// maps user IDs to the tags collected for the sticker they are currently tagging
+	userPendingTags map[int64][]string
+	// maps user IDs to the automatic tags of the sticker they are currently tagging, saved with /done
+	userAutoTags map[int64][]string
+	// users whose tags are added to the whole pack of their current sticker
+	userTagPack map[int64]bool
+	// maps user IDs to the last sticker they sent, to star it with /fav
+	userLastSticker map[int64]tgbotapi.Sticker
 	// authorized users
 	authorizedUsersList []int64
 )
@@ -30,6 +33,12 @@ var (
 const INITIAL_STATE = ""            // initial state
 const TAG_STATE = "waiting_for_tag" // waiting for the user to tag a sticker
 
+// maximum number of inline results per answer
+const inlinePageSize = 50
+
+// how long in seconds Telegram may cache inline results
+const inlineCacheTime = 30
+
 func init() {
 	// load .env
 	err := godotenv.Load(".env")
@@ -37,27 +46,13 @@ func init() {
 		fmt.Println("Error loading .env file, using environment variables")
 	}
 
-	// initialize firebase db
-	ctx := context.Background()
-	opt := option.WithCredentialsFile(os.Getenv("FIREBASE_CREDENTIALS"))
-	config := &firebase.Config{
-		DatabaseURL: os.Getenv("FIREBASE_DB_URL"),
-	}
-	firebaseApp, err = firebase.NewApp(ctx, config, opt)
-	if err != nil {
-		fmt.Printf("Error initializing firebase app: %v", err)
-		os.Exit(1)
-	}
-
-	firebaseDB, err = firebaseApp.Database(ctx)
-	if err != nil {
-		fmt.Printf("Error initializing firebase database: %v", err)
-		os.Exit(1)
-	}
-
 	// initialize state maps
 	userStates = make(map[int64]string)
-	userCurrentSticker = make(map[int64]string)
+	userCurrentSticker = make(map[int64]tgbotapi.Sticker)
+	userPendingTags = make(map[int64][]string)
+	userAutoTags = make(map[int64][]string)
+	userTagPack = make(map[int64]bool)
+	userLastSticker = make(map[int64]tgbotapi.Sticker)
 }
 
 // Delete a tag
@@ -69,16 +64,21 @@ func DeleteTag(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.Bo
 		return
 	}
 
-	tagToDelete := parts[1]
+	tagToDelete := norm.NFC.String(strings.TrimSpace(parts[1]))
+	if err := validateTag(tagToDelete); err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Invalid tag: %v.", err))
+		bot.Send(msg)
+		return
+	}
 	ctx := context.Background()
-	ref := firebaseDB.NewRef("tags/" + tagToDelete)
-	err := ref.Delete(ctx)
 
+	err := store.DeleteTag(ctx, tagToDelete)
 	if err != nil {
 		logger.Errorf("Failed to delete key: %v", err)
 		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry but it failed to delete the tag. Please try again.")
 		bot.Send(msg)
 	} else {
+		invalidateTagIndex()
 		logger.Infof("Deleted key: %s", tagToDelete)
 		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Successfully deleted tag: %s", tagToDelete))
 		bot.Send(msg)
@@ -89,6 +89,9 @@ func DeleteTag(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.Bo
 // State machine to tag stickers
 func TagSticker(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
 	userID := message.From.ID
+	if message.Sticker != nil {
+		userLastSticker[userID] = *message.Sticker
+	}
 
 	// initial state (state 0)
 	if userStates[userID] == INITIAL_STATE {
@@ -96,6 +99,10 @@ func TagSticker(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.B
 			userStates[userID] = TAG_STATE
 			handleSticker(bot, message, logger)
 			return
+		} else if strings.HasPrefix(message.Text, "/untag") {
+			msg := tgbotapi.NewMessage(message.Chat.ID, "Send me the sticker first, then /untag <tag>")
+			bot.Send(msg)
+			return
 		} else {
 			msg := tgbotapi.NewMessage(message.Chat.ID, "Send me a sticker to tag")
 			bot.Send(msg)
@@ -108,98 +115,231 @@ func TagSticker(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.B
 		if strings.HasPrefix(message.Text, "/abort") {
 			msg := tgbotapi.NewMessage(message.Chat.ID, "Current operation aborted.")
 			bot.Send(msg)
-			userStates[userID] = INITIAL_STATE
-			delete(userCurrentSticker, userID)
+			resetUserState(userID)
+			return
+		} else if strings.HasPrefix(message.Text, "/done") {
+			addTagsToSticker(bot, message, logger)
+			return
+		} else if strings.HasPrefix(message.Text, "/untag") {
+			untagCurrentSticker(bot, message, logger)
 			return
 		} else {
-			addTagToSticker(bot, message, logger)
-			userStates[userID] = INITIAL_STATE
+			collectTags(bot, message)
 			return
 		}
 	}
 }
 
-// Switch state to receive a tag for a sticker
+// Reset the tagging state of a user
+func resetUserState(userID int64) {
+	delete(userStates, userID)
+	delete(userCurrentSticker, userID)
+	delete(userPendingTags, userID)
+	delete(userAutoTags, userID)
+	delete(userTagPack, userID)
+}
+
+// Switch state to receive tags for a sticker
 func handleSticker(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
-	fileID := message.Sticker.FileID
 	userID := message.From.ID
-	// TODO: support multiple tags for a sticker
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Send me a tag for this sticker or use /abort to cancel.")
+
+	// show the existing tags to avoid duplicates
+	ctx := context.Background()
+	var autoTags []string
+	tags, err := store.StickerTags(ctx, message.Sticker.FileUniqueID)
+	if err != nil {
+		logger.Println("Error getting sticker tags:", err)
+	} else if len(tags) == 0 && !preferencesOf(userID, logger).NoAutoTags {
+		// tag new stickers with their emoji and pack title so they can be found right away
+		autoTags = autoTagsOf(bot, message.Sticker, logger)
+		if len(autoTags) > 0 {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("The emoji and pack tags %s are added with /done, "+
+				"remove them with /untag <tag> or turn this off with /autotag off.", strings.Join(autoTags, ", ")))
+			bot.Send(msg)
+		}
+	}
+	if len(tags) > 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("This sticker is tagged with: %s.\n"+
+			"Remove a tag with the buttons below or /untag <tag>.", strings.Join(tags, ", ")))
+		if keyboard, ok := untagKeyboard(message.Sticker.FileUniqueID, tags); ok {
+			msg.ReplyMarkup = keyboard
+		}
+		bot.Send(msg)
+
+		// keep the latest file ID, older ones may no longer be usable
+		if err := store.UpdateFileID(ctx, message.Sticker.FileUniqueID, message.Sticker.FileID); err != nil {
+			logger.Println("Error updating sticker file ID:", err)
+		}
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, "Send me tags for this sticker separated by commas or spaces, "+
+		"then /done to save them or /abort to cancel.")
+	if message.Sticker.SetName != "" {
+		msg.ReplyMarkup = packKeyboard(message.Sticker)
+	}
 	bot.Send(msg)
 
 	userStates[userID] = TAG_STATE
-	userCurrentSticker[userID] = fileID
+	userCurrentSticker[userID] = *message.Sticker
+	userAutoTags[userID] = autoTags
+	delete(userPendingTags, userID)
+	delete(userTagPack, userID)
+}
+
+// Split a message into tags, separated by commas if any, otherwise by spaces
+func parseTags(text string) []string {
+	text = norm.NFC.String(text) // normalize unicode characters
+	var parts []string
+	if strings.Contains(text, ",") {
+		parts = strings.Split(text, ",")
+	} else {
+		parts = strings.Fields(text)
+	}
+
+	var tags []string
+	for _, part := range parts {
+		if tag := strings.TrimSpace(part); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
 }
 
-// Store a tag for a sticker
-func addTagToSticker(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+// Collect tags for the sticker the user is currently tagging
+func collectTags(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	userID := message.From.ID
-	tag := message.Text
-	tag = norm.NFC.String(tag) // normalize unicode characters
+	tags := parseTags(message.Text)
+	if len(tags) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Send me tags separated by commas or spaces, /done to save them or /abort to cancel.")
+		bot.Send(msg)
+		return
+	}
 
-	ctx := context.Background()
-	ref := firebaseDB.NewRef("tags/" + tag)
+	for _, tag := range tags {
+		if err := validateTag(tag); err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Skipped invalid tag %s: %v.", tag, err))
+			bot.Send(msg)
+			continue
+		}
+		if !slices.Contains(userPendingTags[userID], tag) {
+			userPendingTags[userID] = append(userPendingTags[userID], tag)
+		}
+	}
+	if len(userPendingTags[userID]) == 0 {
+		return
+	}
 
-	var stickers []string
-	if err := ref.Get(ctx, &stickers); err != nil {
-		logger.Println("Error getting stickers:", err)
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to add the tag. Please try again.")
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Tags so far: %s. Send more, /done to save them or /abort to cancel.",
+		strings.Join(userPendingTags[userID], ", ")))
+	bot.Send(msg)
+}
+
+// Store all collected tags for a sticker
+func addTagsToSticker(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	userID := message.From.ID
+	tags := slices.Clone(userPendingTags[userID])
+	// the emoji and pack tags only fit the sticker itself, not the whole pack
+	if !userTagPack[userID] {
+		for _, tag := range userAutoTags[userID] {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if len(tags) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "No tags to save yet. Send me some tags or use /abort to cancel.")
 		bot.Send(msg)
 		return
 	}
 
-	fileID := userCurrentSticker[userID]
-	stickers = append(stickers, fileID)
+	// tagging a pack takes a while, do not block other updates
+	sticker := userCurrentSticker[userID]
+	if userTagPack[userID] {
+		resetUserState(userID)
+		go tagStickerSet(bot, message.Chat.ID, sticker.SetName, tags, logger)
+		return
+	}
 
-	if err := ref.Set(ctx, stickers); err != nil {
-		logger.Println("Error adding tag:", err)
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to add the tag. Please try again.")
+	// stickers are keyed by their unique ID, so tagging twice is a no-op
+	ctx := context.Background()
+	stickerTags, err := store.AddTags(ctx, sticker.FileUniqueID, sticker.FileID, tags)
+	if err != nil {
+		logger.Println("Error adding tags:", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to add the tags. Please try again.")
 		bot.Send(msg)
-	} else {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Tag "+tag+" added.")
+		return
+	}
+
+	var newTags, duplicateTags []string
+	for _, tag := range tags {
+		if slices.Contains(stickerTags, tag) {
+			duplicateTags = append(duplicateTags, tag)
+		} else {
+			newTags = append(newTags, tag)
+		}
+	}
+	if len(duplicateTags) > 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("This sticker is already tagged with: %s.",
+			strings.Join(duplicateTags, ", ")))
 		bot.Send(msg)
 	}
+	if len(newTags) == 0 {
+		resetUserState(userID)
+		return
+	}
 
-	// reset state
-	delete(userStates, userID)
-	delete(userCurrentSticker, userID)
+	invalidateTagIndex()
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Tags %s added.", strings.Join(newTags, ", ")))
+	bot.Send(msg)
+	resetUserState(userID)
 }
 
-// Search for stickers with a tag
+// Search for stickers whose tags match the query
 func SearchStickers(bot *tgbotapi.BotAPI, query *tgbotapi.InlineQuery, logger *utils.BotLogger) {
 	// check the user authorization
 	if !utils.IsAuthorizedUser(query.From.ID) {
 		return
 	}
 
-	tag := query.Query
-	results := make([]interface{}, 0)
-
-	ctx := context.Background()
-	ref := firebaseDB.NewRef("tags/" + tag)
-
-	var stickerIDs []string
-	if err := ref.Get(ctx, &stickerIDs); err != nil {
-		if err != iterator.Done {
+	var matches []searchResult
+	if strings.TrimSpace(query.Query) == "" {
+		matches = personalStickers(query.From.ID, logger)
+	} else {
+		var err error
+		matches, err = searchStickers(context.Background(), query.Query)
+		if err != nil {
 			logger.Println("Error searching stickers:", err)
 		}
-		// If tag not found or any other error, return empty results
-		return
+		// the sticker used last time is most likely the one wanted again
+		rankByUsage(matches, query.From.ID, logger)
 	}
 
-	for id, fileID := range stickerIDs {
-		result := tgbotapi.NewInlineQueryResultCachedSticker(fmt.Sprintf("%d", id+1), fileID, "")
-		results = append(results, result)
+	// Telegram accepts at most `inlinePageSize` results per answer
+	offset, err := strconv.Atoi(query.Offset)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	// always answer, an empty answer without next offset stops the client from waiting
+	offset = min(offset, len(matches))
+	end := min(offset+inlinePageSize, len(matches))
+	nextOffset := ""
+	if end < len(matches) {
+		nextOffset = strconv.Itoa(end)
 	}
 
-	if len(results) == 0 {
-		// no results found
-		return
+	results := make([]interface{}, 0, end-offset)
+	for id := offset; id < end; id++ {
+		result := tgbotapi.NewInlineQueryResultCachedSticker(matches[id].UniqueID, matches[id].FileID, "")
+		results = append(results, result)
 	}
 
 	inlineConf := tgbotapi.InlineConfig{
 		InlineQueryID: query.ID,
 		Results:       results,
+		NextOffset:    nextOffset,
+		CacheTime:     inlineCacheTime,
+		// results must not be cached for other users, they may not be authorized
+		IsPersonal: true,
 	}
 
 	if _, err := bot.Request(inlineConf); err != nil {
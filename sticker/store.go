@@ -0,0 +1,54 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	utils "pbaobot/utils"
+)
+
+// A TagStore persists tags and the stickers tagged with them
+// Stickers are identified by their file unique ID and sent by their latest file ID
+type TagStore interface {
+	// Add tags to a sticker, return the tags the sticker had before
+	AddTags(ctx context.Context, uniqueID string, fileID string, tags []string) ([]string, error)
+	// Remove a tag from a sticker, return whether the sticker had the tag
+	// A tag without stickers is dropped
+	RemoveTag(ctx context.Context, uniqueID string, tag string) (bool, error)
+	// Delete a tag from all its stickers
+	DeleteTag(ctx context.Context, tag string) error
+	// Move all stickers from a tag to another, return the number of moved stickers
+	RenameTag(ctx context.Context, from string, to string) (int, error)
+	// Replace the file ID of a tagged sticker
+	UpdateFileID(ctx context.Context, uniqueID string, fileID string) error
+	// Return the tags of a sticker
+	StickerTags(ctx context.Context, uniqueID string) ([]string, error)
+	// Return the stickers of a tag, maps from file unique ID to file ID
+	TagStickers(ctx context.Context, tag string) (map[string]string, error)
+	// Return all tags with their stickers, used to build the search index
+	ListTags(ctx context.Context) (map[string]map[string]string, error)
+}
+
+// the store used by all handlers, set by `InitStore`
+var store TagStore
+
+// Initialize the tag store selected by `TAG_STORE`:
+// "firebase" (default), "file" stored at `TAG_STORE_PATH` or in `DATA_DIR`, or "memory"
+func InitStore(logger *utils.BotLogger) error {
+	var err error
+	switch backend := os.Getenv("TAG_STORE"); backend {
+	case "", "firebase":
+		store, err = newFirebaseStore(context.Background())
+	case "file":
+		store, err = newFileStore(utils.EnvOr("TAG_STORE_PATH", utils.DataPath("tags.json")))
+	case "memory":
+		store = newMemoryStore()
+	default:
+		err = fmt.Errorf("unknown tag store %q", backend)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infof("Using %T as tag store", store)
+	return nil
+}
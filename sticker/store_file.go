@@ -0,0 +1,72 @@
+package sticker
+
+import (
+	"context"
+	utils "pbaobot/utils"
+)
+
+// A TagStore keeping everything in memory and saving it to a JSON file after each change,
+// for self-hosting without Firebase
+type fileStore struct {
+	*memoryStore
+	path string
+}
+
+func newFileStore(path string) (*fileStore, error) {
+	memory := newMemoryStore()
+	if err := utils.LoadJSON(path, memory); err != nil {
+		return nil, err
+	}
+	if memory.Tags == nil {
+		memory.Tags = make(map[string]map[string]string)
+	}
+	if memory.Stickers == nil {
+		memory.Stickers = make(map[string][]string)
+	}
+	return &fileStore{memoryStore: memory, path: path}, nil
+}
+
+// Save all tags to the file
+func (s *fileStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return utils.SaveJSON(s.path, s.memoryStore)
+}
+
+func (s *fileStore) AddTags(ctx context.Context, uniqueID string, fileID string, tags []string) ([]string, error) {
+	previous, err := s.memoryStore.AddTags(ctx, uniqueID, fileID, tags)
+	if err != nil {
+		return nil, err
+	}
+	return previous, s.save()
+}
+
+func (s *fileStore) RemoveTag(ctx context.Context, uniqueID string, tag string) (bool, error) {
+	removed, err := s.memoryStore.RemoveTag(ctx, uniqueID, tag)
+	if err != nil || !removed {
+		return removed, err
+	}
+	return true, s.save()
+}
+
+func (s *fileStore) DeleteTag(ctx context.Context, tag string) error {
+	if err := s.memoryStore.DeleteTag(ctx, tag); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *fileStore) RenameTag(ctx context.Context, from string, to string) (int, error) {
+	moved, err := s.memoryStore.RenameTag(ctx, from, to)
+	if err != nil || moved == 0 {
+		return moved, err
+	}
+	return moved, s.save()
+}
+
+func (s *fileStore) UpdateFileID(ctx context.Context, uniqueID string, fileID string) error {
+	if err := s.memoryStore.UpdateFileID(ctx, uniqueID, fileID); err != nil {
+		return err
+	}
+	return s.save()
+}
@@ -0,0 +1,202 @@
+package sticker
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/db"
+	"google.golang.org/api/option"
+)
+
+// A TagStore backed by the Firebase realtime database
+// Tags are stored at `tags/<encoded tag>/<file unique ID> -> file ID`
+// and the reverse index at `stickers/<file unique ID>/<encoded tag> -> true`
+type firebaseStore struct {
+	client *db.Client
+}
+
+// Connect to the database at `FIREBASE_DB_URL` with the credentials at `FIREBASE_CREDENTIALS`
+func newFirebaseStore(ctx context.Context) (*firebaseStore, error) {
+	opt := option.WithCredentialsFile(os.Getenv("FIREBASE_CREDENTIALS"))
+	config := &firebase.Config{
+		DatabaseURL: os.Getenv("FIREBASE_DB_URL"),
+	}
+	app, err := firebase.NewApp(ctx, config, opt)
+	if err != nil {
+		return nil, err
+	}
+	client, err := app.Database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &firebaseStore{client: client}, nil
+}
+
+// number of times a transaction is run again after the client gave up on contention
+const transactionRetries = 3
+
+// Run a transaction, retrying with backoff if it keeps conflicting with other writers
+// The client already retries conflicting writes, this covers heavier contention
+func runTransaction(ctx context.Context, ref *db.Ref, fn db.UpdateFn) error {
+	return retryOnContention(func() error { return ref.Transaction(ctx, fn) })
+}
+
+// Run an operation again with backoff while the client gives up because of contention
+func retryOnContention(run func() error) error {
+	var err error
+	for attempt := 0; attempt <= transactionRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(rand.Intn(100*attempt)+50) * time.Millisecond)
+		}
+		err = run()
+		if err == nil || !strings.Contains(err.Error(), "transaction aborted after failed retries") {
+			return err
+		}
+	}
+	return err
+}
+
+// Return the path of a tag in the reverse index of a sticker
+func stickerTagPath(uniqueID string, tag string) string {
+	return "stickers/" + uniqueID + "/" + encodeTagKey(tag)
+}
+
+// Write the given paths in a single atomic update
+// Stickers are single children of tags and tags single children of stickers,
+// so concurrent writers of different stickers or tags never overwrite each other
+func (s *firebaseStore) update(ctx context.Context, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	return s.client.NewRef("").Update(ctx, updates)
+}
+
+func (s *firebaseStore) AddTags(ctx context.Context, uniqueID string, fileID string, tags []string) ([]string, error) {
+	previous, err := s.StickerTags(ctx, uniqueID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	for _, tag := range tags {
+		updates[tagPath(tag)+"/"+uniqueID] = fileID
+		updates[stickerTagPath(uniqueID, tag)] = true
+	}
+	return previous, s.update(ctx, updates)
+}
+
+func (s *firebaseStore) RemoveTag(ctx context.Context, uniqueID string, tag string) (bool, error) {
+	var tagged bool
+	if err := s.client.NewRef(stickerTagPath(uniqueID, tag)).Get(ctx, &tagged); err != nil {
+		return false, err
+	}
+	if !tagged {
+		return false, nil
+	}
+
+	// empty parents are dropped by Firebase, so a tag without stickers disappears
+	err := s.update(ctx, map[string]interface{}{
+		tagPath(tag) + "/" + uniqueID: nil,
+		stickerTagPath(uniqueID, tag): nil,
+	})
+	return err == nil, err
+}
+
+func (s *firebaseStore) DeleteTag(ctx context.Context, tag string) error {
+	stickers, err := s.TagStickers(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	// only remove the stickers read, stickers tagged meanwhile stay consistent
+	updates := make(map[string]interface{})
+	for uniqueID := range stickers {
+		updates[tagPath(tag)+"/"+uniqueID] = nil
+		updates[stickerTagPath(uniqueID, tag)] = nil
+	}
+	return s.update(ctx, updates)
+}
+
+func (s *firebaseStore) RenameTag(ctx context.Context, from string, to string) (int, error) {
+	stickers, err := s.TagStickers(ctx, from)
+	if err != nil {
+		return 0, err
+	}
+
+	updates := make(map[string]interface{})
+	for uniqueID, fileID := range stickers {
+		updates[tagPath(from)+"/"+uniqueID] = nil
+		updates[stickerTagPath(uniqueID, from)] = nil
+		updates[tagPath(to)+"/"+uniqueID] = fileID
+		updates[stickerTagPath(uniqueID, to)] = true
+	}
+	if err := s.update(ctx, updates); err != nil {
+		return 0, err
+	}
+	return len(stickers), nil
+}
+
+func (s *firebaseStore) UpdateFileID(ctx context.Context, uniqueID string, fileID string) error {
+	tags, err := s.StickerTags(ctx, uniqueID)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		// only replace the file ID if the sticker is still tagged, it may have been removed meanwhile
+		err := runTransaction(ctx, s.client.NewRef(tagPath(tag)+"/"+uniqueID), func(node db.TransactionNode) (interface{}, error) {
+			var current string
+			if err := node.Unmarshal(&current); err != nil {
+				return nil, err
+			}
+			if current == "" {
+				return nil, nil
+			}
+			return fileID, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *firebaseStore) StickerTags(ctx context.Context, uniqueID string) ([]string, error) {
+	var keys map[string]bool
+	if err := s.client.NewRef("stickers/"+uniqueID).Get(ctx, &keys); err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(keys))
+	for key := range keys {
+		tags = append(tags, decodeTagKey(key))
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (s *firebaseStore) TagStickers(ctx context.Context, tag string) (map[string]string, error) {
+	var stickers map[string]string
+	err := s.client.NewRef(tagPath(tag)).Get(ctx, &stickers)
+	return stickers, err
+}
+
+func (s *firebaseStore) ListTags(ctx context.Context) (map[string]map[string]string, error) {
+	var rawTags map[string]json.RawMessage
+	if err := s.client.NewRef("tags").Get(ctx, &rawTags); err != nil {
+		return nil, err
+	}
+	tags := make(map[string]map[string]string)
+	for key, rawStickers := range rawTags {
+		var stickers map[string]string
+		// skip nodes which are not sticker maps, e.g. not migrated yet
+		if err := json.Unmarshal(rawStickers, &stickers); err == nil {
+			tags[decodeTagKey(key)] = stickers
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,188 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	firebase "firebase.google.com/go/v4"
+)
+
+// namespace of the emulator database used by the tests
+const emulatorNamespace = "pbaobot-test"
+
+// Return a Firebase store on an emptied emulator database, or nil without `FIREBASE_DATABASE_EMULATOR_HOST`
+// Start the emulator with `firebase emulators:start --only database`
+func emulatorStore(t *testing.T) *firebaseStore {
+	t.Helper()
+	host := os.Getenv("FIREBASE_DATABASE_EMULATOR_HOST")
+	if host == "" {
+		return nil
+	}
+	url := host
+	if !strings.Contains(url, "?") {
+		url += "?ns=" + emulatorNamespace
+	}
+	ctx := context.Background()
+	app, err := firebase.NewApp(ctx, &firebase.Config{DatabaseURL: url})
+	if err != nil {
+		t.Fatalf("firebase.NewApp() error = %v", err)
+	}
+	client, err := app.Database(ctx)
+	if err != nil {
+		t.Fatalf("Database() error = %v", err)
+	}
+	if err := client.NewRef("").Delete(ctx); err != nil {
+		t.Fatalf("clearing the emulator database failed: %v", err)
+	}
+	return &firebaseStore{client: client}
+}
+
+// Return a Firebase store on the emulator, skip the test without it
+func requireEmulatorStore(t *testing.T) *firebaseStore {
+	t.Helper()
+	s := emulatorStore(t)
+	if s == nil {
+		t.Skip("FIREBASE_DATABASE_EMULATOR_HOST not set")
+	}
+	return s
+}
+
+func TestFirebaseRemoveTag(t *testing.T) {
+	s := requireEmulatorStore(t)
+	ctx := context.Background()
+	if _, err := s.AddTags(ctx, "a", "filea", []string{"cat", "a/b"}); err != nil {
+		t.Fatalf("AddTags() error = %v", err)
+	}
+
+	if removed, err := s.RemoveTag(ctx, "a", "dog"); removed || err != nil {
+		t.Errorf("RemoveTag() of a missing tag = %v, %v, want false", removed, err)
+	}
+	if removed, err := s.RemoveTag(ctx, "a", "a/b"); !removed || err != nil {
+		t.Errorf("RemoveTag() = %v, %v, want true", removed, err)
+	}
+	if removed, err := s.RemoveTag(ctx, "a", "a/b"); removed || err != nil {
+		t.Errorf("RemoveTag() twice = %v, %v, want false", removed, err)
+	}
+
+	// the tag without stickers is dropped
+	tags, err := s.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	if _, ok := tags["a/b"]; ok || len(tags["cat"]) != 1 {
+		t.Errorf("ListTags() = %v, want only cat", tags)
+	}
+	if stickerTags, err := s.StickerTags(ctx, "a"); err != nil || len(stickerTags) != 1 || stickerTags[0] != "cat" {
+		t.Errorf("StickerTags() = %v, %v, want [cat]", stickerTags, err)
+	}
+}
+
+func TestFirebaseConcurrentRemoveSameTag(t *testing.T) {
+	const writers = 20
+	s := requireEmulatorStore(t)
+	ctx := context.Background()
+	if _, err := s.AddTags(ctx, "a", "filea", []string{"cat", "dog"}); err != nil {
+		t.Fatalf("AddTags() error = %v", err)
+	}
+
+	// all writers may see the tag before one removes it, the result must stay consistent
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	removals := 0
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			removed, err := s.RemoveTag(ctx, "a", "cat")
+			if err != nil {
+				t.Errorf("RemoveTag() error = %v", err)
+			}
+			if removed {
+				mu.Lock()
+				removals++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if removals == 0 {
+		t.Errorf("no RemoveTag() reported the removal")
+	}
+	if stickers, err := s.TagStickers(ctx, "cat"); err != nil || len(stickers) != 0 {
+		t.Errorf("TagStickers(cat) = %v, %v, want none", stickers, err)
+	}
+	if stickerTags, err := s.StickerTags(ctx, "a"); err != nil || len(stickerTags) != 1 || stickerTags[0] != "dog" {
+		t.Errorf("StickerTags() = %v, %v, want [dog]", stickerTags, err)
+	}
+	checkConsistent(t, s)
+}
+
+func TestFirebaseDeleteTag(t *testing.T) {
+	s := requireEmulatorStore(t)
+	ctx := context.Background()
+	for _, uniqueID := range []string{"a", "b"} {
+		if _, err := s.AddTags(ctx, uniqueID, "file"+uniqueID, []string{"cat", "dog"}); err != nil {
+			t.Fatalf("AddTags() error = %v", err)
+		}
+	}
+
+	if err := s.DeleteTag(ctx, "cat"); err != nil {
+		t.Fatalf("DeleteTag() error = %v", err)
+	}
+	// deleting a missing tag writes nothing
+	if err := s.DeleteTag(ctx, "cat"); err != nil {
+		t.Fatalf("DeleteTag() of a missing tag error = %v", err)
+	}
+
+	tags, err := s.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	if _, ok := tags["cat"]; ok || len(tags["dog"]) != 2 {
+		t.Errorf("ListTags() = %v, want only dog with 2 stickers", tags)
+	}
+	checkConsistent(t, s)
+}
+
+func TestFirebaseUpdateFileID(t *testing.T) {
+	const writers = 20
+	s := requireEmulatorStore(t)
+	ctx := context.Background()
+	if _, err := s.AddTags(ctx, "a", "old", []string{"cat", "dog"}); err != nil {
+		t.Fatalf("AddTags() error = %v", err)
+	}
+
+	// update the file ID while the sticker loses one of its tags
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.UpdateFileID(ctx, "a", fmt.Sprintf("new%d", i)); err != nil {
+				t.Errorf("UpdateFileID() error = %v", err)
+			}
+		}(i)
+	}
+	if _, err := s.RemoveTag(ctx, "a", "cat"); err != nil {
+		t.Errorf("RemoveTag() error = %v", err)
+	}
+	wg.Wait()
+
+	tags, err := s.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	// the removed sticker is not brought back by the update of its file ID
+	if _, ok := tags["cat"]; ok {
+		t.Errorf("tag cat has stickers %v after the removal", tags["cat"])
+	}
+	if fileID := tags["dog"]["a"]; !strings.HasPrefix(fileID, "new") {
+		t.Errorf("file ID = %q, want an updated one", fileID)
+	}
+	checkConsistent(t, s)
+}
@@ -0,0 +1,122 @@
+package sticker
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// A TagStore keeping everything in memory, data is lost on restart
+type memoryStore struct {
+	mu sync.Mutex
+	// maps from tag to the file unique IDs of its stickers to their file IDs
+	Tags map[string]map[string]string `json:"tags"`
+	// maps from file unique ID to the tags of the sticker
+	Stickers map[string][]string `json:"stickers"`
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		Tags:     make(map[string]map[string]string),
+		Stickers: make(map[string][]string),
+	}
+}
+
+func (s *memoryStore) AddTags(ctx context.Context, uniqueID string, fileID string, tags []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := slices.Clone(s.Stickers[uniqueID])
+	for _, tag := range tags {
+		if s.Tags[tag] == nil {
+			s.Tags[tag] = make(map[string]string)
+		}
+		s.Tags[tag][uniqueID] = fileID
+		if !slices.Contains(s.Stickers[uniqueID], tag) {
+			s.Stickers[uniqueID] = append(s.Stickers[uniqueID], tag)
+		}
+	}
+	return previous, nil
+}
+
+func (s *memoryStore) RemoveTag(ctx context.Context, uniqueID string, tag string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.Stickers[uniqueID], tag) {
+		return false, nil
+	}
+	s.removeTag(uniqueID, tag)
+	return true, nil
+}
+
+// Remove a tag from a sticker, the caller must hold `mu`
+func (s *memoryStore) removeTag(uniqueID string, tag string) {
+	s.Stickers[uniqueID] = slices.DeleteFunc(s.Stickers[uniqueID], func(t string) bool { return t == tag })
+	if len(s.Stickers[uniqueID]) == 0 {
+		delete(s.Stickers, uniqueID)
+	}
+	delete(s.Tags[tag], uniqueID)
+	if len(s.Tags[tag]) == 0 {
+		delete(s.Tags, tag)
+	}
+}
+
+func (s *memoryStore) DeleteTag(ctx context.Context, tag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uniqueID := range s.Tags[tag] {
+		s.removeTag(uniqueID, tag)
+	}
+	return nil
+}
+
+func (s *memoryStore) RenameTag(ctx context.Context, from string, to string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// removeTag empties the map of the old tag, so iterate over a copy
+	stickers := maps.Clone(s.Tags[from])
+	for uniqueID, fileID := range stickers {
+		s.removeTag(uniqueID, from)
+		if s.Tags[to] == nil {
+			s.Tags[to] = make(map[string]string)
+		}
+		s.Tags[to][uniqueID] = fileID
+		if !slices.Contains(s.Stickers[uniqueID], to) {
+			s.Stickers[uniqueID] = append(s.Stickers[uniqueID], to)
+		}
+	}
+	return len(stickers), nil
+}
+
+func (s *memoryStore) UpdateFileID(ctx context.Context, uniqueID string, fileID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range s.Stickers[uniqueID] {
+		if _, ok := s.Tags[tag][uniqueID]; ok {
+			s.Tags[tag][uniqueID] = fileID
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) StickerTags(ctx context.Context, uniqueID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.Stickers[uniqueID]), nil
+}
+
+func (s *memoryStore) TagStickers(ctx context.Context, tag string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.Tags[tag]), nil
+}
+
+func (s *memoryStore) ListTags(ctx context.Context) (map[string]map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags := make(map[string]map[string]string, len(s.Tags))
+	for tag, stickers := range s.Tags {
+		tags[tag] = maps.Clone(stickers)
+	}
+	return tags, nil
+}
@@ -0,0 +1,306 @@
+package sticker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// Return a fresh instance of each store, with Firebase only if its emulator is running
+func testStores(t *testing.T) map[string]TagStore {
+	fs, err := newFileStore(filepath.Join(t.TempDir(), "tags.json"))
+	if err != nil {
+		t.Fatalf("newFileStore() error = %v", err)
+	}
+	stores := map[string]TagStore{"memory": newMemoryStore(), "file": fs}
+	if firebase := emulatorStore(t); firebase != nil {
+		stores["firebase"] = firebase
+	}
+	return stores
+}
+
+// Check that a file store saved all its tags by loading the file again
+func checkSaved(t *testing.T, s TagStore) {
+	t.Helper()
+	fs, ok := s.(*fileStore)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+	reloaded, err := newFileStore(fs.path)
+	if err != nil {
+		t.Fatalf("newFileStore() error = %v", err)
+	}
+	saved, err := reloaded.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	current, err := fs.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	if !maps.EqualFunc(saved, current, maps.Equal) {
+		t.Errorf("saved tags = %v, want %v", saved, current)
+	}
+}
+
+// Check that the tags and the reverse index agree
+func checkConsistent(t *testing.T, s TagStore) {
+	t.Helper()
+	ctx := context.Background()
+	tags, err := s.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	for tag, stickers := range tags {
+		if len(stickers) == 0 {
+			t.Errorf("tag %s has no stickers but is listed", tag)
+		}
+		for uniqueID := range stickers {
+			stickerTags, err := s.StickerTags(ctx, uniqueID)
+			if err != nil {
+				t.Fatalf("StickerTags() error = %v", err)
+			}
+			if !slices.Contains(stickerTags, tag) {
+				t.Errorf("sticker %s is in tag %s but its tags are %v", uniqueID, tag, stickerTags)
+			}
+		}
+	}
+}
+
+func TestConcurrentAddTags(t *testing.T) {
+	const writers = 50
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					uniqueID := fmt.Sprintf("sticker%d", i)
+					if _, err := s.AddTags(ctx, uniqueID, "file"+uniqueID, []string{"cat", fmt.Sprintf("tag%d", i)}); err != nil {
+						t.Errorf("AddTags() error = %v", err)
+					}
+					// a second writer of the same sticker
+					if _, err := s.AddTags(ctx, uniqueID, "file"+uniqueID, []string{"cute"}); err != nil {
+						t.Errorf("AddTags() error = %v", err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			for _, tag := range []string{"cat", "cute"} {
+				stickers, err := s.TagStickers(ctx, tag)
+				if err != nil {
+					t.Fatalf("TagStickers() error = %v", err)
+				}
+				if len(stickers) != writers {
+					t.Errorf("tag %s has %d stickers, want %d", tag, len(stickers), writers)
+				}
+			}
+			for i := 0; i < writers; i++ {
+				tags, err := s.StickerTags(ctx, fmt.Sprintf("sticker%d", i))
+				if err != nil {
+					t.Fatalf("StickerTags() error = %v", err)
+				}
+				if len(tags) != 3 {
+					t.Errorf("sticker%d has tags %v, want 3 tags", i, tags)
+				}
+			}
+			checkConsistent(t, s)
+			checkSaved(t, s)
+		})
+	}
+}
+
+func TestConcurrentRemoveTag(t *testing.T) {
+	const writers = 50
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < writers; i++ {
+				uniqueID := fmt.Sprintf("sticker%d", i)
+				if _, err := s.AddTags(ctx, uniqueID, "file"+uniqueID, []string{"cat", "dog"}); err != nil {
+					t.Fatalf("AddTags() error = %v", err)
+				}
+			}
+
+			// remove "cat" from every sticker while tagging them with "bird"
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(2)
+				uniqueID := fmt.Sprintf("sticker%d", i)
+				go func() {
+					defer wg.Done()
+					removed, err := s.RemoveTag(ctx, uniqueID, "cat")
+					if err != nil || !removed {
+						t.Errorf("RemoveTag() = %v, %v, want true", removed, err)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					if _, err := s.AddTags(ctx, uniqueID, "file"+uniqueID, []string{"bird"}); err != nil {
+						t.Errorf("AddTags() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			tags, err := s.ListTags(ctx)
+			if err != nil {
+				t.Fatalf("ListTags() error = %v", err)
+			}
+			if _, ok := tags["cat"]; ok {
+				t.Errorf("tag cat still has stickers %v", tags["cat"])
+			}
+			if len(tags["dog"]) != writers || len(tags["bird"]) != writers {
+				t.Errorf("dog has %d and bird %d stickers, want %d", len(tags["dog"]), len(tags["bird"]), writers)
+			}
+			checkConsistent(t, s)
+			checkSaved(t, s)
+		})
+	}
+}
+
+func TestConcurrentRenameTag(t *testing.T) {
+	const writers = 50
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < writers; i++ {
+				uniqueID := fmt.Sprintf("old%d", i)
+				if _, err := s.AddTags(ctx, uniqueID, "file"+uniqueID, []string{"from"}); err != nil {
+					t.Fatalf("AddTags() error = %v", err)
+				}
+			}
+
+			// stickers tagged during the rename end up in either tag, never lost
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// the old stickers and any new ones tagged before the rename are moved
+				moved, err := s.RenameTag(ctx, "from", "to")
+				if err != nil || moved < writers || moved > 2*writers {
+					t.Errorf("RenameTag() = %d, %v, want between %d and %d", moved, err, writers, 2*writers)
+				}
+			}()
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					uniqueID := fmt.Sprintf("new%d", i)
+					if _, err := s.AddTags(ctx, uniqueID, "file"+uniqueID, []string{"from", "other"}); err != nil {
+						t.Errorf("AddTags() error = %v", err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			tags, err := s.ListTags(ctx)
+			if err != nil {
+				t.Fatalf("ListTags() error = %v", err)
+			}
+			for i := 0; i < writers; i++ {
+				if _, ok := tags["to"][fmt.Sprintf("old%d", i)]; !ok {
+					t.Errorf("old%d was not moved to the new tag", i)
+				}
+			}
+			if got := len(tags["from"]) + len(tags["to"]); got != 2*writers {
+				t.Errorf("the tags have %d stickers, want %d", got, 2*writers)
+			}
+			if len(tags["other"]) != writers {
+				t.Errorf("other has %d stickers, want %d", len(tags["other"]), writers)
+			}
+			checkConsistent(t, s)
+			checkSaved(t, s)
+		})
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tags.json")
+	fs, err := newFileStore(path)
+	if err != nil {
+		t.Fatalf("newFileStore() error = %v", err)
+	}
+	stores := map[string]TagStore{"memory": newMemoryStore(), "file": fs}
+	if firebase := emulatorStore(t); firebase != nil {
+		stores["firebase"] = firebase
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, uniqueID := range []string{"a", "b"} {
+				if _, err := s.AddTags(ctx, uniqueID, "file"+uniqueID, []string{"lol"}); err != nil {
+					t.Fatalf("AddTags() error = %v", err)
+				}
+			}
+			if _, err := s.AddTags(ctx, "c", "filec", []string{"funny"}); err != nil {
+				t.Fatalf("AddTags() error = %v", err)
+			}
+
+			if moved, err := s.RenameTag(ctx, "lol", "LOL"); moved != 2 || err != nil {
+				t.Errorf("RenameTag(lol, LOL) = %d, %v, want 2", moved, err)
+			}
+			if moved, err := s.RenameTag(ctx, "LOL", "funny"); moved != 2 || err != nil {
+				t.Errorf("RenameTag(LOL, funny) = %d, %v, want 2", moved, err)
+			}
+			if moved, err := s.RenameTag(ctx, "lol", "LOL"); moved != 0 || err != nil {
+				t.Errorf("RenameTag() of a missing tag = %d, %v, want 0", moved, err)
+			}
+			checkConsistent(t, s)
+			checkSaved(t, s)
+		})
+	}
+
+	// the merge must have been saved
+	reloaded, err := newFileStore(path)
+	if err != nil {
+		t.Fatalf("newFileStore() error = %v", err)
+	}
+	tags, err := reloaded.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	if len(tags) != 1 || len(tags["funny"]) != 3 {
+		t.Errorf("reloaded tags = %v, want only funny with 3 stickers", tags)
+	}
+}
+
+func TestRetryOnContention(t *testing.T) {
+	contention := errors.New("transaction aborted after failed retries")
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"succeeds at once", 0, contention, 1, false},
+		{"succeeds after contention", 2, contention, 3, false},
+		{"gives up after the retries", transactionRetries + 1, contention, transactionRetries + 1, true},
+		{"does not retry other errors", 1, errors.New("permission denied"), 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryOnContention(func() error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			})
+			if calls != tt.wantCalls || (err != nil) != tt.wantErr {
+				t.Errorf("retryOnContention() = %v after %d calls, want error %v after %d calls", err, calls, tt.wantErr, tt.wantCalls)
+			}
+		})
+	}
+}
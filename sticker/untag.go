@@ -0,0 +1,130 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	utils "pbaobot/utils"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/text/unicode/norm"
+)
+
+// prefix of the callback data of untag buttons, followed by "<file unique ID>:<tag>"
+const untagCallbackPrefix = "untag:"
+
+// Telegram limits callback data to 64 bytes
+const maxCallbackDataLength = 64
+
+// Return an inline keyboard with a button to remove each tag from the sticker
+// Tags too long to fit in the callback data can only be removed with /untag
+func untagKeyboard(uniqueID string, tags []string) (tgbotapi.InlineKeyboardMarkup, bool) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, tag := range tags {
+		data := untagCallbackPrefix + uniqueID + ":" + tag
+		if len(data) > maxCallbackDataLength {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Remove "+tag, data)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...), len(rows) > 0
+}
+
+// Remove a tag from a single sticker
+func removeTagFromSticker(ctx context.Context, uniqueID string, tag string) (bool, error) {
+	removed, err := store.RemoveTag(ctx, uniqueID, tag)
+	if removed {
+		invalidateTagIndex()
+	}
+	return removed, err
+}
+
+// Remove a tag from the sticker the user is currently tagging
+func untagCurrentSticker(bot *tgbotapi.BotAPI, message *tgbotapi.Message, logger *utils.BotLogger) {
+	parts := strings.SplitN(message.Text, " ", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Remove a tag from this sticker with /untag <tag>")
+		bot.Send(msg)
+		return
+	}
+
+	tag := norm.NFC.String(strings.TrimSpace(parts[1]))
+	if err := validateTag(tag); err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Invalid tag: %v.", err))
+		bot.Send(msg)
+		return
+	}
+	// automatic tags are not saved before /done
+	userID := message.From.ID
+	if slices.Contains(userAutoTags[userID], tag) {
+		userAutoTags[userID] = slices.DeleteFunc(slices.Clone(userAutoTags[userID]), func(t string) bool { return t == tag })
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Tag %s will not be added. Send more tags, /done or /abort.", tag))
+		bot.Send(msg)
+		return
+	}
+
+	sticker := userCurrentSticker[userID]
+	removed, err := removeTagFromSticker(context.Background(), sticker.FileUniqueID, tag)
+	if err != nil {
+		logger.Errorf("Failed to remove tag %s: %v", tag, err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, but it failed to remove the tag. Please try again.")
+		bot.Send(msg)
+		return
+	}
+
+	text := fmt.Sprintf("Tag %s removed from this sticker. Send more tags, /done or /abort.", tag)
+	if !removed {
+		text = fmt.Sprintf("This sticker is not tagged with %s.", tag)
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	bot.Send(msg)
+}
+
+// Handle a press on an inline keyboard button
+func HandleCallbackQuery(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, logger *utils.BotLogger) {
+	if strings.HasPrefix(query.Data, packCallbackPrefix) {
+		handlePackCallback(bot, query, logger)
+		return
+	}
+	if !strings.HasPrefix(query.Data, untagCallbackPrefix) {
+		bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(query.Data, untagCallbackPrefix), ":", 2)
+	if len(parts) != 2 {
+		bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	uniqueID, tag := parts[0], parts[1]
+
+	removed, err := removeTagFromSticker(context.Background(), uniqueID, tag)
+	if err != nil {
+		logger.Errorf("Failed to remove tag %s: %v", tag, err)
+		bot.Request(tgbotapi.NewCallback(query.ID, "Sorry, but it failed to remove the tag. Please try again."))
+		return
+	}
+
+	text := fmt.Sprintf("Tag %s removed.", tag)
+	if !removed {
+		text = fmt.Sprintf("This sticker is not tagged with %s.", tag)
+	}
+	bot.Request(tgbotapi.NewCallback(query.ID, text))
+
+	// drop the button of the removed tag
+	if query.Message != nil && query.Message.ReplyMarkup != nil {
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, row := range query.Message.ReplyMarkup.InlineKeyboard {
+			if len(row) > 0 && row[0].CallbackData != nil && *row[0].CallbackData == query.Data {
+				continue
+			}
+			rows = append(rows, row)
+		}
+		edit := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID,
+			tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+		if _, err := bot.Request(edit); err != nil {
+			logger.Errorf("Error updating keyboard: %v", err)
+		}
+	}
+}
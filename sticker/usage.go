@@ -0,0 +1,97 @@
+package sticker
+
+import (
+	"context"
+	utils "pbaobot/utils"
+	"sort"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// How often stickers were chosen from the inline results
+type stickerUsage struct {
+	// maps from tag to file unique ID to the number of times the sticker was chosen for the tag
+	Tags map[string]map[string]int `json:"tags"`
+	// maps from user ID to file unique ID to the number of times the user chose the sticker
+	Users map[int64]map[string]int `json:"users"`
+	// maps from user ID to the stickers they chose, most recent first
+	Recent map[int64][]savedSticker `json:"recent"`
+}
+
+// the stickers chosen so far
+var usage = utils.NewJSONFile("STICKER_USAGE_PATH", "sticker_usage.json", stickerUsage{
+	Tags:   make(map[string]map[string]int),
+	Users:  make(map[int64]map[string]int),
+	Recent: make(map[int64][]savedSticker),
+})
+
+// Count a sticker chosen by a user for the tags it was found with
+func recordUsage(userID int64, uniqueID string, fileID string, tags []string, logger *utils.BotLogger) error {
+	return usage.Update(logger, func(usage *stickerUsage) error {
+		for _, tag := range tags {
+			if usage.Tags[tag] == nil {
+				usage.Tags[tag] = make(map[string]int)
+			}
+			usage.Tags[tag][uniqueID]++
+		}
+		if usage.Users[userID] == nil {
+			usage.Users[userID] = make(map[string]int)
+		}
+		usage.Users[userID][uniqueID]++
+		if fileID != "" {
+			usage.Recent[userID] = pushSticker(usage.Recent[userID], savedSticker{UniqueID: uniqueID, FileID: fileID}, maxRecentStickers)
+		}
+		return nil
+	})
+}
+
+// Sort search results by how often the user chose them, then by how often anyone chose them for the matched tags
+// Results used equally often keep their match order
+func rankByUsage(results []searchResult, userID int64, logger *utils.BotLogger) {
+	usage.View(logger, func(usage stickerUsage) {
+		personal := usage.Users[userID]
+		global := make(map[string]int, len(results))
+		for _, result := range results {
+			for _, tag := range result.Tags {
+				global[result.UniqueID] += usage.Tags[tag][result.UniqueID]
+			}
+		}
+		sort.SliceStable(results, func(a, b int) bool {
+			ua, ub := results[a].UniqueID, results[b].UniqueID
+			if personal[ua] != personal[ub] {
+				return personal[ua] > personal[ub]
+			}
+			return global[ua] > global[ub]
+		})
+	})
+}
+
+// Record the sticker a user chose from the inline results
+// Telegram only sends chosen results if inline feedback is enabled with /setinlinefeedback in @BotFather
+func RecordChosenSticker(result *tgbotapi.ChosenInlineResult, logger *utils.BotLogger) {
+	if !utils.IsAuthorizedUser(result.From.ID) {
+		return
+	}
+
+	// result IDs are file unique IDs, find the tags the query matched
+	matches, err := searchStickers(context.Background(), result.Query)
+	if err != nil {
+		logger.Println("Error searching stickers:", err)
+	}
+	if len(matches) == 0 {
+		// chosen from the favorite and recent stickers of an empty query
+		matches = personalStickers(result.From.ID, logger)
+	}
+	var tags []string
+	fileID := ""
+	for _, match := range matches {
+		if match.UniqueID == result.ResultID {
+			tags, fileID = match.Tags, match.FileID
+			break
+		}
+	}
+
+	if err := recordUsage(result.From.ID, result.ResultID, fileID, tags, logger); err != nil {
+		logger.Errorf("Error saving sticker usage: %v", err)
+	}
+}
@@ -9,7 +9,11 @@ import (
 	"github.com/joho/godotenv"
 )
 
-var authorizedUsersList []int64
+var (
+	authorizedUsersList []int64
+	// users allowed to use admin commands
+	adminUsersList []int64
+)
 
 func init() {
 	// load .env
@@ -28,6 +32,18 @@ func init() {
 		}
 		authorizedUsersList = append(authorizedUsersList, userID)
 	}
+
+	// parse admin users
+	if os.Getenv("ADMIN_USERS") != "" {
+		for _, userIDStr := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+			userID, err := strconv.ParseInt(userIDStr, 10, 64)
+			if err != nil {
+				fmt.Printf("Error parsing admin user ID %s: %v", userIDStr, err)
+				continue
+			}
+			adminUsersList = append(adminUsersList, userID)
+		}
+	}
 }
 
 // Whether a user is authorized to use the bot
@@ -39,3 +55,13 @@ func IsAuthorizedUser(userID int64) bool {
 	}
 	return false
 }
+
+// Whether a user is allowed to use admin commands
+func IsAdminUser(userID int64) bool {
+	for _, adminUserID := range adminUsersList {
+		if adminUserID == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // embed the timezone database, the server may not ship one
+)
+
+// The timezone used for every date computation of the bot
+const BotTimezone = "Europe/Zurich"
+
+// A clock tells the current time
+// It can be replaced to simulate edge times
+type Clock interface {
+	Now() time.Time
+}
+
+// A clock returning the current time in a fixed location
+type zoneClock struct {
+	location *time.Location
+}
+
+func (c zoneClock) Now() time.Time {
+	return time.Now().In(c.location)
+}
+
+// A clock always returning the same time, in the bot timezone
+type FixedClock struct {
+	Time time.Time
+}
+
+func (c FixedClock) Now() time.Time {
+	return c.Time.In(Location)
+}
+
+var (
+	// the bot timezone location
+	Location *time.Location
+	// the clock used for every date computation
+	clock Clock
+)
+
+func init() {
+	var err error
+	Location, err = time.LoadLocation(BotTimezone)
+	if err != nil {
+		fmt.Printf("Error loading timezone %s: %v, using UTC", BotTimezone, err)
+		Location = time.UTC
+	}
+	clock = zoneClock{location: Location}
+}
+
+// Replace the clock, e.g. with a `FixedClock` in tests
+// Return the previous clock to restore it
+func SetClock(c Clock) Clock {
+	previous := clock
+	clock = c
+	return previous
+}
+
+// Return the current time in the bot timezone
+func Now() time.Time {
+	return clock.Now()
+}
+
+// Return the current date in the format "YYYY-MM-DD"
+func Today() string {
+	return Now().Format("2006-01-02")
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Return the environment variable or the fallback if it is not set
+func EnvOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Load a JSON file into v, a missing file leaves v untouched
+func LoadJSON(path string, v interface{}) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+// Save v as a JSON file, replacing the file atomically
+func SaveJSON(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// Return the path of a data file in `DATA_DIR`, the working directory if it is not set
+// Point `DATA_DIR` to a persistent disk, the working directory is lost on every deploy
+func DataPath(name string) string {
+	return filepath.Join(os.Getenv("DATA_DIR"), name)
+}
+
+// A value stored as a JSON file at the path in an environment variable,
+// loaded on first use and saved after each update
+// The file is never written before it was loaded, so an unreadable file is not overwritten
+type JSONFile[T any] struct {
+	envKey      string
+	defaultPath string
+	value       T
+	loaded      bool
+	mu          sync.Mutex
+}
+
+// Return a JSON file stored at `envKey` or `defaultPath` in `DATA_DIR`, holding `initial` until it is loaded
+// Maps in `initial` must not be nil so they can be written if the file is missing
+func NewJSONFile[T any](envKey string, defaultPath string, initial T) *JSONFile[T] {
+	return &JSONFile[T]{envKey: envKey, defaultPath: defaultPath, value: initial}
+}
+
+// Return where the file is stored
+func (f *JSONFile[T]) Path() string {
+	return EnvOr(f.envKey, DataPath(f.defaultPath))
+}
+
+// Load the file if it is not loaded yet, the caller must hold `mu`
+// A failed load is tried again on the next access
+func (f *JSONFile[T]) load() error {
+	if f.loaded {
+		return nil
+	}
+	if err := LoadJSON(f.Path(), &f.value); err != nil {
+		return fmt.Errorf("failed to load %s: %w", f.Path(), err)
+	}
+	f.loaded = true
+	return nil
+}
+
+// Read the value, `fn` must not keep references to it
+// `fn` is not called if the file cannot be loaded
+func (f *JSONFile[T]) View(logger *BotLogger, fn func(value T)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		logger.Errorf("Error reading JSON file: %v", err)
+		return err
+	}
+	fn(f.value)
+	return nil
+}
+
+// Change the value and save it, unless `fn` returns an error
+// `fn` is not called if the file cannot be loaded
+func (f *JSONFile[T]) Update(logger *BotLogger, fn func(value *T) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return err
+	}
+	if err := fn(&f.value); err != nil {
+		return err
+	}
+	return SaveJSON(f.Path(), f.value)
+}
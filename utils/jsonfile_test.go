@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.json")
+	t.Setenv("TEST_COUNTS_PATH", path)
+	logger := NewBotLogger(io.Discard)
+
+	counts := NewJSONFile("TEST_COUNTS_PATH", "unused.json", make(map[string]int))
+	if err := counts.Update(logger, func(counts *map[string]int) error {
+		(*counts)["a"]++
+		return nil
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// a failed update is not saved
+	errFailed := errors.New("failed")
+	if err := counts.Update(logger, func(counts *map[string]int) error {
+		(*counts)["b"]++
+		return errFailed
+	}); !errors.Is(err, errFailed) {
+		t.Fatalf("Update() error = %v, want %v", err, errFailed)
+	}
+
+	// a new instance loads the saved file
+	reloaded := NewJSONFile("TEST_COUNTS_PATH", "unused.json", make(map[string]int))
+	reloaded.View(logger, func(counts map[string]int) {
+		if counts["a"] != 1 || counts["b"] != 0 {
+			t.Errorf("loaded %v, want a: 1", counts)
+		}
+	})
+}
+
+func TestJSONFileNotLoaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.json")
+	t.Setenv("TEST_COUNTS_PATH", path)
+	logger := NewBotLogger(io.Discard)
+	if err := os.WriteFile(path, []byte(`{"a": 5`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := NewJSONFile("TEST_COUNTS_PATH", "unused.json", make(map[string]int))
+	if err := counts.View(logger, func(counts map[string]int) {
+		t.Errorf("View() read %v from a corrupt file", counts)
+	}); err == nil {
+		t.Errorf("View() of a corrupt file succeeded")
+	}
+	if err := counts.Update(logger, func(counts *map[string]int) error {
+		t.Errorf("Update() changed %v from a corrupt file", *counts)
+		return nil
+	}); err == nil {
+		t.Errorf("Update() of a corrupt file succeeded")
+	}
+	// the corrupt file is kept to be repaired
+	if content, err := os.ReadFile(path); err != nil || string(content) != `{"a": 5` {
+		t.Errorf("file content = %q, %v, want it untouched", content, err)
+	}
+
+	// the file is loaded once it is repaired
+	if err := os.WriteFile(path, []byte(`{"a": 5}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := counts.Update(logger, func(counts *map[string]int) error {
+		(*counts)["a"]++
+		return nil
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	counts.View(logger, func(counts map[string]int) {
+		if counts["a"] != 6 {
+			t.Errorf("loaded %v, want a: 6", counts)
+		}
+	})
+}
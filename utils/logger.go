@@ -5,7 +5,6 @@ import (
 	"io"
 	"os"
 	"sync"
-	"time"
 )
 
 // A bot logger used to log everything
@@ -24,7 +23,7 @@ func (l *BotLogger) log(level, format string, args ...interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	timestamp := time.Now().Format("2006/01/02 15:04:05")
+	timestamp := Now().Format("2006/01/02 15:04:05")
 	msg := fmt.Sprintf(format, args...)
 	fmt.Fprintf(l.writer, "[%s] %s: %s\n", timestamp, level, msg)
 }